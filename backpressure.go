@@ -0,0 +1,171 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// BackpressurePoll is how often the output queue of a congested File
+// is checked against the low watermark.
+var BackpressurePoll = 10 * time.Millisecond
+
+// backpressure tracks the congestion state of the write direction of a
+// File and delivers its changes to the channel returned by
+// Backpressure.
+type backpressure struct {
+	m         sync.Mutex
+	f         *File
+	high, low int
+	congested bool
+	closed    bool
+	timer     *time.Timer
+	c         chan bool
+}
+
+// outq returns the number of bytes in the output queue of fd.
+func outq(fd int) (int, error) {
+	var n int32
+	if err := ioctl(fd, syscall.TIOCOUTQ, uintptr(unsafe.Pointer(&n))); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// io is the hook called with the result of every write attempt.
+func (bp *backpressure) io(n int, err error) {
+	if err == syscall.EAGAIN {
+		bp.set(true)
+		return
+	}
+	if n > 0 {
+		bp.check()
+	}
+}
+
+// check compares the output queue with the watermarks. If the queue
+// length is unavailable (e.g. pipes) File is uncongested once it's
+// writable again.
+func (bp *backpressure) check() {
+	q, err := outq(bp.f.fd)
+	switch {
+	case err != nil:
+		bp.set(false)
+	case q >= bp.high:
+		bp.set(true)
+	case q <= bp.low:
+		bp.set(false)
+	}
+}
+
+func (bp *backpressure) set(congested bool) {
+	bp.m.Lock()
+	defer bp.m.Unlock()
+	if bp.closed || bp.congested == congested {
+		return
+	}
+	bp.congested = congested
+	// Replace the pending state, if any.
+	select {
+	case <-bp.c:
+	default:
+	}
+	bp.c <- congested
+	if congested && bp.timer == nil {
+		if _, err := outq(bp.f.fd); err == nil {
+			bp.timer = time.AfterFunc(BackpressurePoll, bp.poll)
+		}
+	}
+}
+
+func (bp *backpressure) poll() {
+	bp.check()
+	bp.m.Lock()
+	defer bp.m.Unlock()
+	if bp.congested && !bp.closed {
+		bp.timer.Reset(BackpressurePoll)
+	} else {
+		bp.timer = nil
+	}
+}
+
+func (bp *backpressure) event(f *File, ev Events) {
+	if ev == 0 {
+		// File closed.
+		bp.m.Lock()
+		bp.closed = true
+		if bp.timer != nil {
+			bp.timer.Stop()
+		}
+		close(bp.c)
+		bp.m.Unlock()
+		return
+	}
+	if ev&Writable != 0 {
+		bp.m.Lock()
+		congested := bp.congested
+		bp.m.Unlock()
+		if congested {
+			bp.check()
+		}
+	}
+}
+
+// Backpressure returns a channel receiving true when the output of
+// File becomes congested, and false when it drains, so producers can
+// pause generating data instead of blocking on Write. File is
+// congested when a write finds it not ready (EAGAIN) or its output
+// queue (TIOCOUTQ) reaches high bytes, and drains when the queue goes
+// down to low bytes or, if the queue length is unavailable, when File
+// becomes writable again. Only the latest state is kept while the
+// receiver is busy. The channel is closed when File is closed.
+// Backpressure can be set up only once per File; further calls fail
+// with ErrBusy.
+func (f *File) Backpressure(high, low int) (<-chan bool, error) {
+	if high <= 0 || low < 0 || low > high {
+		return nil, syscall.EINVAL
+	}
+	bp := &backpressure{f: f, high: high, low: low, c: make(chan bool, 1)}
+	f.wm.Lock()
+	if f.bp != nil {
+		f.wm.Unlock()
+		return nil, ErrBusy
+	}
+	f.bp = bp
+	f.wm.Unlock()
+	f.addWatcher(&watcher{fn: bp.event})
+	f.w.cond.L.Lock()
+	closed := f.closed
+	if !closed {
+		f.w.hook = bp.io
+	}
+	f.w.cond.L.Unlock()
+	if closed {
+		bp.event(f, 0)
+	}
+	return bp.c, nil
+}
+
+// OnBackpressure calls fn, from its own goroutine, with the changes of
+// the congestion state of File. See Backpressure.
+func (f *File) OnBackpressure(high, low int, fn func(congested bool)) error {
+	c, err := f.Backpressure(high, low)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for congested := range c {
+			fn(congested)
+		}
+	}()
+	return nil
+}
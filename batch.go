@@ -0,0 +1,119 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "sync"
+
+// BatchOp is a read or write queued in a Batch. N and Err hold its
+// result once Batch.Wait returns.
+type BatchOp struct {
+	File  *File
+	Write bool
+	Buf   []byte
+	N     int
+	Err   error
+}
+
+// Batch submits reads and writes on several Files at once and waits
+// for all of them to complete. Reads behave as File.Read and writes as
+// File.Write, deadlines included. Operations on different Files, or on
+// different directions of the same File, run concurrently, while those
+// on the same File and direction run one after another, in queuing
+// order, so queued writes don't get mixed up. Once one of them fails,
+// the following ones aren't attempted and fail with the same error.
+//
+// The operations are currently run by goroutines parked on the event
+// loop; there is no io_uring backend yet, which would allow submitting
+// a whole Batch with a single system call.
+type Batch struct {
+	ops       []*BatchOp
+	wg        sync.WaitGroup
+	submitted bool
+}
+
+// Read queues a read from f into p.
+func (b *Batch) Read(f *File, p []byte) *BatchOp {
+	op := &BatchOp{File: f, Buf: p}
+	b.ops = append(b.ops, op)
+	return op
+}
+
+// Write queues a write of p to f.
+func (b *Batch) Write(f *File, p []byte) *BatchOp {
+	op := &BatchOp{File: f, Write: true, Buf: p}
+	b.ops = append(b.ops, op)
+	return op
+}
+
+// Len returns the number of operations queued.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Submit starts the queued operations. It returns ErrBusy if the Batch
+// was already submitted.
+func (b *Batch) Submit() error {
+	if b.submitted {
+		return ErrBusy
+	}
+	b.submitted = true
+	type key struct {
+		f     *File
+		write bool
+	}
+	var groups [][]*BatchOp
+	index := map[key]int{}
+	for _, op := range b.ops {
+		k := key{op.File, op.Write}
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], op)
+	}
+	b.wg.Add(len(groups))
+	for _, g := range groups {
+		go func(g []*BatchOp) {
+			defer b.wg.Done()
+			var err error
+			for _, op := range g {
+				if err != nil {
+					op.Err = err
+					continue
+				}
+				if op.Write {
+					op.N, op.Err = op.File.Write(op.Buf)
+				} else {
+					op.N, op.Err = op.File.Read(op.Buf)
+				}
+				err = op.Err
+			}
+		}(g)
+	}
+	return nil
+}
+
+// Wait waits for the submitted operations to complete and returns the
+// first error found, in queuing order. The results of every operation
+// are in its BatchOp. After Wait the Batch is empty and can be reused.
+func (b *Batch) Wait() error {
+	if !b.submitted {
+		return nil
+	}
+	b.wg.Wait()
+	var err error
+	for _, op := range b.ops {
+		if op.Err != nil {
+			err = op.Err
+			break
+		}
+	}
+	b.ops = nil
+	b.submitted = false
+	return err
+}
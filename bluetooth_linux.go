@@ -0,0 +1,110 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Bluetooth socket constants, missing from package syscall.
+const (
+	afBluetooth   = 31
+	btprotoL2CAP  = 0
+	btprotoRFCOMM = 3
+)
+
+// BDAddr is a Bluetooth device address, in display order (most
+// significant byte first).
+type BDAddr [6]byte
+
+// ParseBDAddr parses a Bluetooth device address like
+// "00:1A:7D:DA:71:13".
+func ParseBDAddr(s string) (BDAddr, error) {
+	var a BDAddr
+	if len(s) != 17 {
+		return a, fmt.Errorf("poll: invalid Bluetooth address %q", s)
+	}
+	for i := range a {
+		if i > 0 && s[i*3-1] != ':' {
+			return a, fmt.Errorf("poll: invalid Bluetooth address %q", s)
+		}
+		b, err := strconv.ParseUint(s[i*3:i*3+2], 16, 8)
+		if err != nil {
+			return a, fmt.Errorf("poll: invalid Bluetooth address %q", s)
+		}
+		a[i] = byte(b)
+	}
+	return a, nil
+}
+
+// String returns the address in the usual notation.
+func (a BDAddr) String() string {
+	return fmt.Sprintf("%02X:%02X:%02X:%02X:%02X:%02X", a[0], a[1], a[2], a[3], a[4], a[5])
+}
+
+// bdaddr returns a in the byte order of bdaddr_t (reversed).
+func (a BDAddr) bdaddr() [6]byte {
+	var b [6]byte
+	for i := range a {
+		b[i] = a[5-i]
+	}
+	return b
+}
+
+// sockaddrRC is struct sockaddr_rc.
+type sockaddrRC struct {
+	family  uint16
+	bdaddr  [6]byte
+	channel uint8
+	_       uint8
+}
+
+// sockaddrL2 is struct sockaddr_l2. Multi-byte fields are little
+// endian.
+type sockaddrL2 struct {
+	family     uint16
+	psm        [2]byte
+	bdaddr     [6]byte
+	cid        [2]byte
+	bdaddrType uint8
+	_          uint8
+}
+
+// DialRFCOMM connects to RFCOMM channel (1 to 30) of the Bluetooth
+// device addr, giving up at deadline (zero means no deadline). RFCOMM
+// is the serial port emulation used by Bluetooth serial adapters. The
+// Conn is served by the default Poller.
+func DialRFCOMM(addr BDAddr, channel int, deadline time.Time) (*Conn, error) {
+	sa := sockaddrRC{family: afBluetooth, bdaddr: addr.bdaddr(), channel: uint8(channel)}
+	name := addr.String() + "/" + strconv.Itoa(channel)
+	f, err := dialRaw(afBluetooth, syscall.SOCK_STREAM, btprotoRFCOMM, name,
+		unsafe.Pointer(&sa), unsafe.Sizeof(sa), deadline)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(f, nil, Addr{Net: "rfcomm", Name: name}), nil
+}
+
+// DialL2CAP connects to the L2CAP protocol/service multiplexer psm of
+// the Bluetooth device addr with a sequential packet socket, giving up
+// at deadline (zero means no deadline). Every Read returns one packet.
+// The Conn is served by the default Poller.
+func DialL2CAP(addr BDAddr, psm int, deadline time.Time) (*Conn, error) {
+	sa := sockaddrL2{family: afBluetooth, bdaddr: addr.bdaddr()}
+	binary.LittleEndian.PutUint16(sa.psm[:], uint16(psm))
+	name := addr.String() + "/" + strconv.Itoa(psm)
+	f, err := dialRaw(afBluetooth, syscall.SOCK_SEQPACKET, btprotoL2CAP, name,
+		unsafe.Pointer(&sa), unsafe.Sizeof(sa), deadline)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(f, nil, Addr{Net: "l2cap", Name: name}), nil
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "time"
+
+// BusyPollConfig configures adaptive busy polling (see SetBusyPoll).
+type BusyPollConfig struct {
+	// Spin is how long an operation which finds File not ready
+	// keeps retrying before waiting for the event loop.
+	Spin time.Duration
+	// Busy polling is enabled when the rate of completed operations
+	// reaches HighRate (per second), and disabled again when it
+	// falls to LowRate. LowRate < HighRate provides hysteresis.
+	HighRate float64
+	LowRate  float64
+}
+
+// busyPoll keeps the busy polling state of a direction of a File.
+type busyPoll struct {
+	BusyPollConfig
+	last time.Time // Last completed operation
+	avg  float64   // Moving average of the interval between operations, in seconds
+	on   bool
+}
+
+// observe records a completed operation, switching busy polling on or
+// off depending on the rate of operations.
+func (b *busyPoll) observe() {
+	now := time.Now()
+	if !b.last.IsZero() {
+		d := now.Sub(b.last).Seconds()
+		if b.avg == 0 {
+			b.avg = d
+		} else {
+			b.avg += (d - b.avg) / 8
+		}
+		rate := 1 / b.avg
+		if !b.on && rate >= b.HighRate {
+			b.on = true
+		} else if b.on && rate <= b.LowRate {
+			b.on = false
+		}
+	}
+	b.last = now
+}
+
+// SetBusyPoll enables adaptive busy polling for both directions of
+// File. Under load, when operations complete at HighRate or more, an
+// operation which finds File not ready retries for up to cfg.Spin
+// before waiting for the event loop, saving the wake-up latency at the
+// expense of CPU time. When the rate falls to LowRate or less File
+// goes back to waiting for the event loop only. A nil cfg disables
+// busy polling.
+func (f *File) SetBusyPoll(cfg *BusyPollConfig) {
+	for _, fdc := range []*fdCtl{&f.r, &f.w} {
+		fdc.cond.L.Lock()
+		if cfg == nil {
+			fdc.busy = nil
+		} else {
+			fdc.busy = &busyPoll{BusyPollConfig: *cfg}
+		}
+		fdc.cond.L.Unlock()
+	}
+}
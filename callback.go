@@ -0,0 +1,65 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+// OnReadable registers fn to be called whenever File becomes readable
+// (or hangs up, or has an error pending), replacing any previous
+// callback. A nil fn removes the callback. If File is already readable
+// fn is called before OnReadable returns.
+//
+// fn is called from the event loop goroutine, so it must not block:
+// it should only consume the data available (e.g. using short
+// deadlines) or hand the work to another goroutine. This allows
+// serving thousands of mostly idle Files without a goroutine per
+// File. Depending on the backend fn is called on every readiness edge
+// (epoll, kqueue) or for as long as File stays readable (select, poll).
+func (f *File) OnReadable(fn func()) {
+	f.setCallback(false, fn)
+}
+
+// OnWritable registers fn to be called whenever File becomes writable.
+// See OnReadable.
+func (f *File) OnWritable(fn func()) {
+	f.setCallback(true, fn)
+}
+
+func (f *File) setCallback(write bool, fn func()) {
+	var w *watcher
+	if fn != nil {
+		mask := Readable | Hangup | ErrorEvent
+		if write {
+			mask = Writable | Hangup | ErrorEvent
+		}
+		w = &watcher{fn: func(f *File, ev Events) {
+			if ev&mask != 0 {
+				fn()
+				f.p.startTrack(f.fd, write)
+			}
+		}}
+	}
+	f.wm.Lock()
+	old := &f.onR
+	if write {
+		old = &f.onW
+	}
+	prev := *old
+	*old = w
+	f.wm.Unlock()
+	if prev != nil {
+		f.removeWatcher(prev)
+	}
+	if w == nil || f.isClosed() {
+		return
+	}
+	f.addWatcher(w)
+	if ok, _ := ready(f.fd, write); ok {
+		fn()
+	}
+	f.p.startTrack(f.fd, write)
+}
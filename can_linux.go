@@ -0,0 +1,168 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"io"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// SocketCAN constants, missing from package syscall.
+const (
+	afCAN          = 29
+	canRaw         = 1
+	solCANRaw      = 101
+	canRawFilter   = 1
+	canRawFDFrames = 5
+)
+
+// CAN identifier flags and masks.
+const (
+	CANExtendedFlag = 0x80000000 // 29-bit extended frame format
+	CANRemoteFlag   = 0x40000000 // Remote transmission request
+	CANErrorFlag    = 0x20000000 // Error message frame
+	CANStandardMask = 0x000007ff // Standard frame format identifier
+	CANExtendedMask = 0x1fffffff // Extended frame format identifier
+)
+
+// CAN FD frame flags.
+const (
+	CANFDBitRateSwitch = 0x01 // Bit rate switch (BRS)
+	CANFDErrorState    = 0x02 // Error state indicator of the sender (ESI)
+)
+
+// Sizes of struct can_frame and struct canfd_frame.
+const (
+	canFrameSize   = 16
+	canFDFrameSize = 72
+)
+
+// sockaddrCAN is the leading part of struct sockaddr_can, all the
+// kernel requires for raw sockets.
+type sockaddrCAN struct {
+	family  uint16
+	_       uint16
+	ifindex int32
+	_       [2]uint32 // Transport protocol addresses
+}
+
+// CANFrame is a CAN or CAN FD frame.
+type CANFrame struct {
+	ID    uint32 // Identifier, along with CANExtendedFlag, etc.
+	Data  []byte // Up to 8 bytes, or 64 for CAN FD frames
+	FD    bool   // CAN FD frame
+	Flags uint8  // CAN FD flags (CANFDBitRateSwitch, etc.)
+}
+
+// CANFilter selects the received frames whose identifier matches ID in
+// the bits set in Mask (received & Mask == ID & Mask).
+type CANFilter struct {
+	ID   uint32
+	Mask uint32
+}
+
+// CANSocket is a raw SocketCAN socket, see OpenCAN.
+type CANSocket struct {
+	*File
+}
+
+// OpenCAN opens a raw CAN socket bound to the network interface ifname
+// (e.g. "can0" or "vcan0"). If fd is set CAN FD frames can be sent and
+// received too. The socket is served by the default Poller.
+func OpenCAN(ifname string, fd bool) (*CANSocket, error) {
+	ifi, err := net.InterfaceByName(ifname)
+	if err != nil {
+		return nil, err
+	}
+	s, err := sysSocket(afCAN, syscall.SOCK_RAW, canRaw)
+	if err != nil {
+		return nil, &OpError{Op: "open", Name: ifname, Err: err}
+	}
+	if fd {
+		err = syscall.SetsockoptInt(s, solCANRaw, canRawFDFrames, 1)
+	}
+	if err == nil {
+		sa := sockaddrCAN{family: afCAN, ifindex: int32(ifi.Index)}
+		err = rawBind(s, unsafe.Pointer(&sa), unsafe.Sizeof(sa))
+	}
+	if err != nil {
+		syscall.Close(s)
+		return nil, &OpError{Op: "open", Name: ifname, Err: err}
+	}
+	f, err := NewFile(uintptr(s), ifname)
+	if err != nil {
+		syscall.Close(s)
+		return nil, err
+	}
+	return &CANSocket{File: f}, nil
+}
+
+// SetFilters sets the filters for received frames; frames matching any
+// of them are received. By default every frame is received; no filters
+// stop reception.
+func (c *CANSocket) SetFilters(filters []CANFilter) error {
+	if err := c.Lock(); err != nil {
+		return err
+	}
+	defer c.Unlock()
+	var opt string
+	if len(filters) > 0 {
+		// CANFilter has the layout of struct can_filter.
+		opt = string(unsafe.Slice((*byte)(unsafe.Pointer(&filters[0])), len(filters)*int(unsafe.Sizeof(filters[0]))))
+	}
+	return syscall.SetsockoptString(c.fd, solCANRaw, canRawFilter, opt)
+}
+
+// ReadFrame waits, until the read deadline of the socket, for the next
+// frame.
+func (c *CANSocket) ReadFrame() (CANFrame, error) {
+	var buf [canFDFrameSize]byte
+	// Frames are read whole.
+	n, err := c.Read(buf[:])
+	if err != nil {
+		return CANFrame{}, err
+	}
+	if n != canFrameSize && n != canFDFrameSize {
+		return CANFrame{}, io.ErrUnexpectedEOF
+	}
+	fr := CANFrame{ID: *(*uint32)(unsafe.Pointer(&buf[0]))}
+	max := 8
+	if n == canFDFrameSize {
+		fr.FD = true
+		fr.Flags = buf[5]
+		max = 64
+	}
+	size := int(buf[4])
+	if size > max {
+		size = max
+	}
+	fr.Data = append([]byte(nil), buf[8:8+size]...)
+	return fr, nil
+}
+
+// WriteFrame sends a frame, waiting until the write deadline of the
+// socket if the interface queue is full. CAN FD frames require a socket
+// opened with fd set.
+func (c *CANSocket) WriteFrame(fr CANFrame) error {
+	var buf [canFDFrameSize]byte
+	size := canFrameSize
+	max := 8
+	if fr.FD {
+		size = canFDFrameSize
+		max = 64
+		buf[5] = fr.Flags
+	}
+	if len(fr.Data) > max {
+		return c.opError("write", syscall.EINVAL)
+	}
+	*(*uint32)(unsafe.Pointer(&buf[0])) = fr.ID
+	buf[4] = uint8(len(fr.Data))
+	copy(buf[8:], fr.Data)
+	_, err := c.Write(buf[:size])
+	return err
+}
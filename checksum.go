@@ -0,0 +1,166 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Checksum is an integrity check of the frames of a protocol, appended
+// to them (see FrameReader.SetChecksum and FrameWriter.SetChecksum).
+type Checksum interface {
+	// Size returns the size of the checksum in bytes.
+	Size() int
+	// Append appends the checksum of data to dst and returns the
+	// extended buffer.
+	Append(dst, data []byte) []byte
+}
+
+// ChecksumError is the error for a frame whose checksum doesn't match.
+// errors.Is(err, ErrBadChecksum) reports true for it.
+type ChecksumError struct {
+	Frame []byte // The offending frame, including the checksum
+	Want  []byte // The checksum computed for it
+}
+
+// Error returns a string describing the error.
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%v: frame % x, want %x", ErrBadChecksum, e.Frame, e.Want)
+}
+
+// Unwrap returns ErrBadChecksum.
+func (e *ChecksumError) Unwrap() error {
+	return ErrBadChecksum
+}
+
+// VerifyChecksum checks the checksum c at the end of frame and returns
+// frame without it, or a *ChecksumError, e.g. in a RetryPolicy
+// Validate function.
+func VerifyChecksum(c Checksum, frame []byte) ([]byte, error) {
+	n := len(frame) - c.Size()
+	if n < 0 {
+		return nil, &ChecksumError{Frame: append([]byte(nil), frame...)}
+	}
+	var buf [8]byte
+	want := c.Append(buf[:0], frame[:n])
+	if !bytes.Equal(want, frame[n:]) {
+		return nil, &ChecksumError{Frame: append([]byte(nil), frame...), Want: append([]byte(nil), want...)}
+	}
+	return frame[:n], nil
+}
+
+// CRC is a cyclic redundancy check Checksum, defined by the parameters
+// of the Rocksoft model (as listed in the CRC RevEng catalogue).
+type CRC struct {
+	Width     int    // 8, 16 or 32 bits
+	Poly      uint32 // Polynomial, normal (MSB first) form
+	Init      uint32 // Initial value
+	Reflected bool   // Input and output reflected (LSB first)
+	XorOut    uint32 // Final XOR value
+	// Order of the CRC bytes appended to frames, big endian if nil.
+	Order binary.ByteOrder
+}
+
+// Common CRCs.
+var (
+	CRC8SMBus       = &CRC{Width: 8, Poly: 0x07}
+	CRC8Maxim       = &CRC{Width: 8, Poly: 0x31, Reflected: true} // Dallas 1-Wire
+	CRC16Modbus     = &CRC{Width: 16, Poly: 0x8005, Init: 0xffff, Reflected: true, Order: binary.LittleEndian}
+	CRC16XModem     = &CRC{Width: 16, Poly: 0x1021}
+	CRC16CCITTFalse = &CRC{Width: 16, Poly: 0x1021, Init: 0xffff}
+	CRC16Kermit     = &CRC{Width: 16, Poly: 0x1021, Reflected: true, Order: binary.LittleEndian}
+	CRC32IEEE       = &CRC{Width: 32, Poly: 0x04c11db7, Init: 0xffffffff, Reflected: true, XorOut: 0xffffffff, Order: binary.LittleEndian}
+	CRC32C          = &CRC{Width: 32, Poly: 0x1edc6f41, Init: 0xffffffff, Reflected: true, XorOut: 0xffffffff, Order: binary.LittleEndian}
+)
+
+// Size returns the size of the CRC in bytes.
+func (c *CRC) Size() int {
+	return c.Width / 8
+}
+
+// Sum returns the CRC of data.
+func (c *CRC) Sum(data []byte) uint32 {
+	top := uint32(1) << uint(c.Width-1)
+	mask := top<<1 - 1
+	crc := c.Init
+	if c.Reflected {
+		poly := reflect32(c.Poly, c.Width)
+		crc = reflect32(crc, c.Width)
+		for _, b := range data {
+			crc ^= uint32(b)
+			for i := 0; i < 8; i++ {
+				if crc&1 != 0 {
+					crc = crc>>1 ^ poly
+				} else {
+					crc >>= 1
+				}
+			}
+		}
+	} else {
+		for _, b := range data {
+			crc ^= uint32(b) << uint(c.Width-8)
+			for i := 0; i < 8; i++ {
+				if crc&top != 0 {
+					crc = crc<<1 ^ c.Poly
+				} else {
+					crc <<= 1
+				}
+			}
+			crc &= mask
+		}
+	}
+	return (crc ^ c.XorOut) & mask
+}
+
+// Append appends the CRC of data to dst.
+func (c *CRC) Append(dst, data []byte) []byte {
+	order := c.Order
+	if order == nil {
+		order = binary.BigEndian
+	}
+	var b [4]byte
+	sum := c.Sum(data)
+	switch c.Width {
+	case 8:
+		b[0] = uint8(sum)
+	case 16:
+		order.PutUint16(b[:], uint16(sum))
+	default:
+		order.PutUint32(b[:], sum)
+	}
+	return append(dst, b[:c.Size()]...)
+}
+
+// reflect32 reverses the low width bits of v.
+func reflect32(v uint32, width int) uint32 {
+	var r uint32
+	for i := 0; i < width; i++ {
+		r = r<<1 | v&1
+		v >>= 1
+	}
+	return r
+}
+
+// LRC is the longitudinal redundancy check of Modbus ASCII and many
+// other serial protocols: the two's complement of the 8-bit sum of the
+// bytes.
+var LRC Checksum = lrc{}
+
+type lrc struct{}
+
+func (lrc) Size() int {
+	return 1
+}
+
+func (lrc) Append(dst, data []byte) []byte {
+	var sum uint8
+	for _, b := range data {
+		sum += b
+	}
+	return append(dst, -sum)
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "net"
+
+// Addr is a net.Addr naming a File.
+type Addr struct {
+	Net  string // Returned by Network
+	Name string // Returned by String
+}
+
+// Network returns the network name of the address.
+func (a Addr) Network() string {
+	return a.Net
+}
+
+// String returns the address.
+func (a Addr) String() string {
+	return a.Name
+}
+
+// Conn adapts a File to the net.Conn interface, so serial ports,
+// sockets, etc. can be used by libraries that expect a net.Conn.
+type Conn struct {
+	*File
+	local  net.Addr
+	remote net.Addr
+}
+
+// NewConn returns a Conn for f with the given addresses. Nil addresses
+// are replaced by an Addr with network "poll" and the name of f.
+func NewConn(f *File, local, remote net.Addr) *Conn {
+	if local == nil {
+		local = Addr{Net: "poll", Name: f.Name()}
+	}
+	if remote == nil {
+		remote = Addr{Net: "poll", Name: f.Name()}
+	}
+	return &Conn{File: f, local: local, remote: remote}
+}
+
+// Conn returns a net.Conn adapter for File. See NewConn.
+func (f *File) Conn() *Conn {
+	return NewConn(f, nil, nil)
+}
+
+// LocalAddr returns the local address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the remote address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+var _ net.Conn = (*Conn)(nil)
+var _ ReadWriteCloserDeadliner = (*Conn)(nil)
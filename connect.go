@@ -0,0 +1,108 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"syscall"
+	"time"
+)
+
+// Connect connects File, a socket, to sa. If the connection can't be
+// completed immediately, Connect waits for it through the event loop
+// (see WaitConnect). The write deadline applies.
+func (f *File) Connect(sa syscall.Sockaddr) error {
+	return f.connect(func(fd int) error {
+		return syscall.Connect(fd, sa)
+	})
+}
+
+// connect connects File calling fn, which calls connect(2) on the
+// descriptor. See Connect.
+func (f *File) connect(fn func(fd int) error) error {
+	f.w.m.Lock()
+	defer f.w.m.Unlock()
+	_, err := f.sysio(true, func(fd int) (int, error) {
+		return 0, fn(fd)
+	})
+	switch err {
+	case syscall.EINPROGRESS, syscall.EALREADY, syscall.EINTR:
+		err = f.waitConnect()
+	case syscall.EISCONN:
+		err = nil
+	}
+	if err != nil {
+		return f.opError("connect", err)
+	}
+	return nil
+}
+
+// WaitConnect waits for the completion of a connect(2) call on File
+// which returned EINPROGRESS, returning its result (SO_ERROR). The
+// write deadline applies.
+func (f *File) WaitConnect() error {
+	f.w.m.Lock()
+	defer f.w.m.Unlock()
+	if err := f.waitConnect(); err != nil {
+		return f.opError("connect", err)
+	}
+	return nil
+}
+
+func (f *File) waitConnect() error {
+	_, err := f.sysio(true, func(fd int) (int, error) {
+		ok, err := ready(fd, true)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, syscall.EAGAIN
+		}
+		n, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_ERROR)
+		if err != nil {
+			return 0, err
+		}
+		if n != 0 {
+			return 0, syscall.Errno(n)
+		}
+		return 0, nil
+	})
+	return err
+}
+
+// DialSockaddr creates a socket of the given domain, type and protocol
+// (e.g. AF_UNIX, AF_VSOCK or raw sockets, not supported by package net)
+// and connects it to sa, giving up at deadline (zero means no
+// deadline). The File is served by the default Poller and named after
+// sa.
+func DialSockaddr(domain, typ, proto int, sa syscall.Sockaddr, deadline time.Time) (*File, error) {
+	fd, err := sysSocket(domain, typ, proto)
+	if err != nil {
+		return nil, err
+	}
+	name := "socket"
+	if a := sockAddr(sa, typ); a != nil {
+		name = a.String()
+	}
+	f, err := NewFile(uintptr(fd), name)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	if !deadline.IsZero() {
+		f.SetWriteDeadline(deadline)
+	}
+	if err = f.Connect(sa); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !deadline.IsZero() {
+		f.SetWriteDeadline(time.Time{})
+	}
+	return f, nil
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"time"
+)
+
+// ReadContext is like Read, but it gives up with ctx.Err() once ctx is
+// done, either waiting for File to become readable or queued behind
+// other readers. Only this call is canceled; other goroutines reading
+// File keep their place, so a request-scoped ctx can abort its own
+// request on a shared device. The read deadline still applies.
+func (f *File) ReadContext(ctx context.Context, p []byte) (n int, err error) {
+	n, err = f.ioContext(ctx, false, p)
+	if err == nil && n == 0 && len(p) != 0 {
+		err = io.EOF
+	}
+	if err != nil && err != io.EOF {
+		err = f.opError("read", err)
+	}
+	return n, err
+}
+
+// WriteContext is like Write, but it gives up with ctx.Err() once ctx
+// is done. See ReadContext. n may be non-zero on cancellation.
+func (f *File) WriteContext(ctx context.Context, p []byte) (n int, err error) {
+	n, err = f.ioContext(ctx, true, p)
+	if err != nil {
+		err = f.opError("write", err)
+	}
+	return n, err
+}
+
+// ioContext reads once into p, or writes all of p, canceling on ctx.
+// Like Read and Write it uses sysRead and sysWrite, and writes honor
+// SetWriteChunk.
+func (f *File) ioContext(ctx context.Context, write bool, p []byte) (n int, err error) {
+	fdc := &f.r
+	rw := sysRead
+	if write {
+		fdc = &f.w
+		rw = sysWrite
+	}
+	if !fdc.m.lockContext(ctx) {
+		return 0, ctx.Err()
+	}
+	defer fdc.wakeOnDone(ctx)()
+	for {
+		var nn int
+		b := p[n:]
+		if write && fdc.chunk > 0 && len(b) > fdc.chunk {
+			b = b[:fdc.chunk]
+		}
+		nn, err = f.sysio(write, func(fd int) (int, error) {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+			return rw(fd, b)
+		})
+		n += nn
+		if err != nil || !write || n == len(p) {
+			break
+		}
+		if nn == 0 {
+			err = io.ErrUnexpectedEOF
+			break
+		}
+		if fdc.chunk > 0 {
+			// Let other writers in between chunks.
+			fdc.m.Unlock()
+			runtime.Gosched()
+			if !fdc.m.lockContext(ctx) {
+				return n, ctx.Err()
+			}
+		}
+	}
+	fdc.m.Unlock()
+	return n, err
+}
+
+// wakeOnDone wakes up the goroutines waiting on fdc when ctx is done,
+// so the one ctx belongs to notices. Like the events, it bumps fdc.seq,
+// so a goroutine in the middle of a system call doesn't miss it. It
+// returns a function to stop watching ctx.
+func (fdc *fdCtl) wakeOnDone(ctx context.Context) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	c := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			fdc.wake(false, time.Time{})
+		case <-c:
+		}
+	}()
+	return func() { close(c) }
+}
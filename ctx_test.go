@@ -0,0 +1,87 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lateCtx is a context canceled right after its first Err call has
+// returned nil, that is, while the system call is in progress.
+type lateCtx struct {
+	context.Context
+	done chan struct{}
+	once sync.Once
+}
+
+func (c *lateCtx) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *lateCtx) Err() error {
+	err := context.Canceled
+	c.once.Do(func() {
+		close(c.done)
+		// Let wakeOnDone act before the call fails.
+		time.Sleep(10 * time.Millisecond)
+		err = nil
+	})
+	return err
+}
+
+// cancelLoop runs op, blocked on an idle File, with a ctx canceled
+// after a growing delay and with a lateCtx, and checks it returns
+// context.Canceled long before the deadline set by op.
+func cancelLoop(t *testing.T, op func(ctx context.Context) error) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func(d time.Duration) {
+			time.Sleep(d)
+			cancel()
+		}(time.Duration(i%20) * 10 * time.Microsecond)
+		if err := op(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("iteration %d: err = %v", i, err)
+		}
+	}
+	ctx := &lateCtx{Context: context.Background(), done: make(chan struct{})}
+	if err := op(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled during the system call: err = %v", err)
+	}
+}
+
+func TestReadContextCancel(t *testing.T) {
+	r, _ := newPipe(t)
+	buf := make([]byte, 8)
+	cancelLoop(t, func(ctx context.Context) error {
+		r.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, err := r.ReadContext(ctx, buf)
+		return err
+	})
+}
+
+func TestWriteContextChunked(t *testing.T) {
+	r, w := newPipe(t)
+	w.SetWriteChunk(4096)
+	data := make([]byte, 1<<20)
+	got := make(chan int)
+	go func() {
+		n, _ := io.ReadFull(r, make([]byte, len(data)))
+		got <- n
+	}()
+	n, err := w.WriteContext(context.Background(), data)
+	if err != nil || n != len(data) {
+		t.Fatalf("WriteContext = %d, %v", n, err)
+	}
+	if n := <-got; n != len(data) {
+		t.Fatalf("read %d bytes", n)
+	}
+}
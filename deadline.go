@@ -0,0 +1,86 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "time"
+
+// DeadlineCarrier is implemented by objects supporting read and write
+// deadlines, like File and net.Conn.
+type DeadlineCarrier interface {
+	SetDeadline(t time.Time) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// SetDeadline sets the read and write deadlines of x. If x has no
+// SetDeadline method its SetReadDeadline and SetWriteDeadline methods
+// are used instead. ErrNoDeadline is returned if x supports neither.
+func SetDeadline(x interface{}, t time.Time) error {
+	if d, ok := x.(interface{ SetDeadline(time.Time) error }); ok {
+		return d.SetDeadline(t)
+	}
+	errR := SetReadDeadline(x, t)
+	if errR != nil && errR != ErrNoDeadline {
+		return errR
+	}
+	errW := SetWriteDeadline(x, t)
+	if errW == ErrNoDeadline && errR == nil {
+		return nil
+	}
+	return errW
+}
+
+// SetReadDeadline sets the read deadline of x, or returns
+// ErrNoDeadline if x has no SetReadDeadline method.
+func SetReadDeadline(x interface{}, t time.Time) error {
+	if d, ok := x.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return d.SetReadDeadline(t)
+	}
+	return ErrNoDeadline
+}
+
+// SetWriteDeadline sets the write deadline of x, or returns
+// ErrNoDeadline if x has no SetWriteDeadline method.
+func SetWriteDeadline(x interface{}, t time.Time) error {
+	if d, ok := x.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return ErrNoDeadline
+}
+
+// DeadlineForwarder implements DeadlineCarrier by forwarding every
+// call to Target. Wrappers (codecs, rate limiters, multiplexers, etc.)
+// can embed it to expose the deadlines of the object they wrap:
+//
+//	type codec struct {
+//		poll.DeadlineForwarder
+//		f *poll.File
+//	}
+//
+//	c := &codec{DeadlineForwarder: poll.DeadlineForwarder{Target: f}, f: f}
+//
+// Wrappers of wrappers work as well, as long as each level forwards.
+type DeadlineForwarder struct {
+	Target interface{}
+}
+
+// SetDeadline forwards to Target. See the SetDeadline function.
+func (d DeadlineForwarder) SetDeadline(t time.Time) error {
+	return SetDeadline(d.Target, t)
+}
+
+// SetReadDeadline forwards to Target. See the SetReadDeadline function.
+func (d DeadlineForwarder) SetReadDeadline(t time.Time) error {
+	return SetReadDeadline(d.Target, t)
+}
+
+// SetWriteDeadline forwards to Target. See the SetWriteDeadline function.
+func (d DeadlineForwarder) SetWriteDeadline(t time.Time) error {
+	return SetWriteDeadline(d.Target, t)
+}
+
+var _ DeadlineCarrier = (*File)(nil)
+var _ DeadlineCarrier = DeadlineForwarder{}
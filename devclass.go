@@ -0,0 +1,72 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// DeviceClass is the class of a device node, which selects the flags
+// Open adds for the build profile (e.g. O_NOCTTY for ttys on Android).
+type DeviceClass int
+
+// Device classes.
+const (
+	ClassOther DeviceClass = iota // Anything else, including regular files
+	ClassTTY                      // Serial ports and pseudo terminals
+	ClassUSB                      // usbfs nodes (/dev/bus/usb/BBB/DDD)
+	ClassHID                      // hidraw nodes
+	ClassInput                    // evdev nodes (/dev/input/eventN)
+)
+
+// String returns the name of the class.
+func (c DeviceClass) String() string {
+	switch c {
+	case ClassTTY:
+		return "tty"
+	case ClassUSB:
+		return "usb"
+	case ClassHID:
+		return "hid"
+	case ClassInput:
+		return "input"
+	}
+	return "other"
+}
+
+// DeviceClassOf returns the class of the device node at path, judged
+// by its name, or that of the node it links to (e.g. for the names in
+// /dev/serial/by-id).
+func DeviceClassOf(path string) DeviceClass {
+	if c := deviceClass(path); c != ClassOther {
+		return c
+	}
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		return deviceClass(target)
+	}
+	return ClassOther
+}
+
+func deviceClass(path string) DeviceClass {
+	path = filepath.Clean(path)
+	dir, base := filepath.Split(path)
+	switch {
+	case strings.HasPrefix(path, "/dev/bus/usb/"):
+		return ClassUSB
+	case dir == "/dev/input/" && strings.HasPrefix(base, "event"):
+		return ClassInput
+	case dir == "/dev/" && strings.HasPrefix(base, "hidraw"):
+		return ClassHID
+	case dir == "/dev/pts/",
+		dir == "/dev/" && (strings.HasPrefix(base, "tty") ||
+			strings.HasPrefix(base, "cu.") ||
+			strings.HasPrefix(base, "rfcomm") ||
+			base == "ptmx" || base == "console"):
+		return ClassTTY
+	}
+	return ClassOther
+}
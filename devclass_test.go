@@ -0,0 +1,82 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+)
+
+var deviceClassTests = []struct {
+	path  string
+	class DeviceClass
+}{
+	{"/dev/ttyUSB0", ClassTTY},
+	{"/dev/ttyACM1", ClassTTY},
+	{"/dev/ttyS0", ClassTTY},
+	{"/dev/ttyHS1", ClassTTY},
+	{"/dev/ttyMSM0", ClassTTY},
+	{"/dev/cu.usbserial-A1", ClassTTY},
+	{"/dev/rfcomm0", ClassTTY},
+	{"/dev/pts/3", ClassTTY},
+	{"/dev/ptmx", ClassTTY},
+	{"/dev/bus/usb/001/004", ClassUSB},
+	{"/dev/bus/usb/002/001/", ClassUSB},
+	{"/dev/hidraw2", ClassHID},
+	{"/dev/input/event5", ClassInput},
+	{"/dev/input/mice", ClassOther},
+	{"/dev/null", ClassOther},
+	{"/dev/usb/ttyUSB0", ClassOther},
+	{"/tmp/ttyUSB0", ClassOther},
+	{"ttyUSB0", ClassOther},
+}
+
+func TestDeviceClassOf(t *testing.T) {
+	for _, tt := range deviceClassTests {
+		if c := DeviceClassOf(tt.path); c != tt.class {
+			t.Errorf("DeviceClassOf(%q) = %v, want %v", tt.path, c, tt.class)
+		}
+	}
+}
+
+func TestDeviceClassSymlink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "usb-FTDI_FT232R-if00-port0")
+	if err := os.Symlink("/dev/ttyUSB0", link); err != nil {
+		t.Skip(err)
+	}
+	// The target needn't exist for the link to be classified, but
+	// EvalSymlinks fails if it doesn't.
+	if _, err := os.Stat("/dev/ttyUSB0"); err != nil {
+		if c := DeviceClassOf(link); c != ClassOther {
+			t.Errorf("DeviceClassOf(dangling link) = %v, want other", c)
+		}
+		return
+	}
+	if c := DeviceClassOf(link); c != ClassTTY {
+		t.Errorf("DeviceClassOf(link to a tty) = %v, want tty", c)
+	}
+}
+
+// TestProfileFlags checks the flags the build profile adds to Open for
+// every device class.
+func TestProfileFlags(t *testing.T) {
+	for _, tt := range deviceClassTests {
+		want := 0
+		if runtime.GOOS == "android" && tt.class == ClassTTY {
+			want = syscall.O_NOCTTY
+		}
+		if got := classFlags(tt.class); got != want {
+			t.Errorf("classFlags(%v) = %#x, want %#x", tt.class, got, want)
+		}
+		if got := openFlags(tt.path); got != want {
+			t.Errorf("openFlags(%q) = %#x, want %#x", tt.path, got, want)
+		}
+	}
+}
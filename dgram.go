@@ -0,0 +1,49 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// ReadFromAddr reads a single datagram from an unconnected socket (UDP,
+// unix datagram, raw, etc.) returning the address of its sender. If the
+// datagram doesn't fit in p, the first len(p) bytes are returned, the
+// rest is discarded and the error is ErrTruncated. Empty datagrams are
+// valid, so n == 0 doesn't mean end of file. The read deadline applies.
+func (f *File) ReadFromAddr(p []byte) (n int, from syscall.Sockaddr, err error) {
+	var flags int
+	f.r.m.Lock()
+	n, err = f.sysio(false, func(fd int) (int, error) {
+		var n int
+		var err error
+		n, _, flags, from, err = syscall.Recvmsg(fd, p, nil, 0)
+		return n, err
+	})
+	f.r.m.Unlock()
+	if err != nil {
+		return 0, nil, f.opError("receive", err)
+	}
+	if flags&syscall.MSG_TRUNC != 0 {
+		err = ErrTruncated
+	}
+	return n, from, err
+}
+
+// WriteToAddr sends p as a single datagram to the address to. The write
+// deadline applies.
+func (f *File) WriteToAddr(p []byte, to syscall.Sockaddr) (n int, err error) {
+	f.w.m.Lock()
+	n, err = f.sysio(true, func(fd int) (int, error) {
+		return syscall.SendmsgN(fd, p, nil, to, 0)
+	})
+	f.w.m.Unlock()
+	if err != nil {
+		err = f.opError("send", err)
+	}
+	return n, err
+}
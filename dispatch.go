@@ -0,0 +1,195 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+)
+
+// Matcher reports whether frame is the response a request waits for.
+type Matcher func(frame []byte) bool
+
+// MatchPrefix returns a Matcher accepting the frames starting with
+// prefix, e.g. the identifier of a request or the echo of an AT
+// command.
+func MatchPrefix(prefix []byte) Matcher {
+	return func(frame []byte) bool {
+		return bytes.HasPrefix(frame, prefix)
+	}
+}
+
+// MatchID returns a Matcher accepting the frames with identifier id, as
+// extracted by idOf.
+func MatchID(idOf func(frame []byte) (id uint64, ok bool), id uint64) Matcher {
+	return func(frame []byte) bool {
+		fid, ok := idOf(frame)
+		return ok && fid == id
+	}
+}
+
+// pendingReq is a request waiting for its response.
+type pendingReq struct {
+	match Matcher
+	resp  chan []byte // Buffered, receives the response
+}
+
+// Dispatcher demultiplexes the frames received from a device which
+// interleaves responses to requests with unsolicited frames
+// (notifications, alarms, etc.), like modems and many industrial
+// controllers. It reads frames continuously, routing each one to the
+// oldest pending Transact whose Matcher accepts it, or to the
+// Unsolicited channel otherwise.
+type Dispatcher struct {
+	r           *FrameReader
+	w           *FrameWriter
+	unsolicited chan []byte
+	cancel      context.CancelFunc
+	done        chan struct{} // Closed when the read loop exits
+	m           sync.Mutex
+	pending     []*pendingReq
+	dropped     uint64
+	err         error // Why the read loop exited
+}
+
+// NewDispatcher starts a Dispatcher reading frames from r and writing
+// requests to w. Unsolicited frames are queued, up to queue of them,
+// to the Unsolicited channel; frames which don't fit are dropped (see
+// Dropped), so a slow consumer doesn't hold responses back. Nobody else
+// may read from the File of r while the Dispatcher runs, and its read
+// deadline must not be set.
+func NewDispatcher(r *FrameReader, w *FrameWriter, queue int) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		r:           r,
+		w:           w,
+		unsolicited: make(chan []byte, queue),
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	go d.loop(ctx)
+	return d
+}
+
+func (d *Dispatcher) loop(ctx context.Context) {
+	defer close(d.done)
+	defer close(d.unsolicited)
+	for {
+		frame, err := d.r.ReadFrameContext(ctx)
+		if err == ErrTruncated || errors.Is(err, ErrBadChecksum) {
+			// Corrupted frame, skip it.
+			continue
+		}
+		if err != nil {
+			d.m.Lock()
+			d.err = err
+			d.pending = nil
+			d.m.Unlock()
+			return
+		}
+		frame = append([]byte(nil), frame...)
+		d.m.Lock()
+		var req *pendingReq
+		for i, p := range d.pending {
+			if p.match(frame) {
+				req = p
+				d.pending = append(d.pending[:i], d.pending[i+1:]...)
+				break
+			}
+		}
+		if req != nil {
+			d.m.Unlock()
+			req.resp <- frame
+			continue
+		}
+		select {
+		case d.unsolicited <- frame:
+		default:
+			d.dropped++
+		}
+		d.m.Unlock()
+	}
+}
+
+// Transact writes the request frame req and waits for the response
+// accepted by match, which it returns. It gives up with ctx.Err() once
+// ctx is done; a late response is then handled as unsolicited. If the
+// Dispatcher stops, it fails with the reason (see Err). Transact may be
+// called concurrently.
+func (d *Dispatcher) Transact(ctx context.Context, req []byte, match Matcher) ([]byte, error) {
+	p := &pendingReq{match: match, resp: make(chan []byte, 1)}
+	d.m.Lock()
+	if d.err != nil {
+		err := d.err
+		d.m.Unlock()
+		return nil, err
+	}
+	// Registered before writing, responses may be fast.
+	d.pending = append(d.pending, p)
+	d.m.Unlock()
+	if err := d.w.WriteFrameContext(ctx, req); err != nil {
+		d.remove(p)
+		return nil, err
+	}
+	select {
+	case resp := <-p.resp:
+		return resp, nil
+	case <-ctx.Done():
+		d.remove(p)
+		return nil, ctx.Err()
+	case <-d.done:
+		// The response may have been routed just before.
+		select {
+		case resp := <-p.resp:
+			return resp, nil
+		default:
+		}
+		return nil, d.Err()
+	}
+}
+
+// remove drops p from the pending requests.
+func (d *Dispatcher) remove(p *pendingReq) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	for i, x := range d.pending {
+		if x == p {
+			d.pending = append(d.pending[:i], d.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// Unsolicited returns the channel unsolicited frames are delivered to.
+// It's closed when the Dispatcher stops.
+func (d *Dispatcher) Unsolicited() <-chan []byte {
+	return d.unsolicited
+}
+
+// Dropped returns the number of unsolicited frames dropped because the
+// Unsolicited channel was full.
+func (d *Dispatcher) Dropped() uint64 {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d.dropped
+}
+
+// Err returns why the Dispatcher stopped (e.g. io.EOF, ErrClosed or
+// context.Canceled after Stop), nil while it runs.
+func (d *Dispatcher) Err() error {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d.err
+}
+
+// Stop stops the Dispatcher, without closing its File, and waits for
+// the read loop to exit. Pending and later Transacts fail.
+func (d *Dispatcher) Stop() {
+	d.cancel()
+	<-d.done
+}
@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// Driver returns the name of the kernel driver of the tty File. It is
+// only supported on Linux.
+func (f *File) Driver() (string, error) {
+	return "", syscall.ENOTSUP
+}
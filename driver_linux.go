@@ -0,0 +1,75 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Driver returns the name of the kernel driver of the tty File (e.g.
+// "ftdi_sio", "cdc_acm" or "serial8250"), as reported by sysfs. Ttys
+// without a device in sysfs (e.g. IrCOMM) report the tty driver name
+// in /proc/tty/drivers instead (e.g. "ircomm").
+func (f *File) Driver() (string, error) {
+	path, err := filepath.EvalSymlinks(f.name)
+	if err != nil {
+		return "", err
+	}
+	link, err := os.Readlink(filepath.Join("/sys/class/tty", filepath.Base(path), "device/driver"))
+	if err == nil {
+		return filepath.Base(link), nil
+	}
+	if name, perr := ttyDriver(path); perr == nil {
+		return name, nil
+	}
+	return "", err
+}
+
+// ttyDriver returns the name of the tty driver of the device file path
+// from /proc/tty/drivers, whose lines are like
+// "ircomm /dev/ircomm 161 0-31 serial".
+func ttyDriver(path string) (string, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return "", err
+	}
+	dev := uint64(st.Rdev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	fd, err := os.Open("/proc/tty/drivers")
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close()
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		if m, err := strconv.ParseUint(fields[2], 10, 32); err != nil || m != major {
+			continue
+		}
+		lo, hi := fields[3], fields[3]
+		if i := strings.IndexByte(lo, '-'); i >= 0 {
+			lo, hi = lo[:i], lo[i+1:]
+		}
+		a, err1 := strconv.ParseUint(lo, 10, 32)
+		b, err2 := strconv.ParseUint(hi, 10, 32)
+		if err1 == nil && err2 == nil && minor >= a && minor <= b {
+			return fields[0], nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", syscall.ENODEV
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"encoding/json"
+	"io"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// DirState is a snapshot of one direction (read or write) of a File.
+type DirState struct {
+	Deadline  *time.Time    `json:",omitempty"` // Nil if no deadline is set
+	TimedOut  bool          // Deadline expired
+	Waiters   int           // Goroutines waiting for readiness
+	Spurious  uint64        // Wake-ups which found File not ready
+	BusyPoll  bool          // Busy polling (see SetBusyPoll)
+	Bytes     uint64        // Bytes transferred
+	LastEvent *time.Time    `json:",omitempty"` // Last time the loop reported File ready
+	Latency   *LatencyStats `json:",omitempty"` // Set if SetLatencyHistograms
+	LastErr   string        `json:",omitempty"` // Last error returned by the system
+}
+
+// FileState is a snapshot of the state of a File.
+type FileState struct {
+	Name   string
+	Fd     int
+	Closed bool
+	Read   DirState
+	Write  DirState
+}
+
+// PollerState is a snapshot of the state of a Poller, as written by
+// DumpState.
+type PollerState struct {
+	Time       time.Time
+	Files      []FileState
+	Goroutines string `json:",omitempty"` // Stacks of all goroutines
+}
+
+func (fdc *fdCtl) state() DirState {
+	fdc.cond.L.Lock()
+	defer fdc.cond.L.Unlock()
+	s := DirState{
+		TimedOut: fdc.timeout,
+		Waiters:  fdc.waiters,
+		Spurious: fdc.spurious,
+		BusyPoll: fdc.busy != nil && fdc.busy.on,
+		Bytes:    fdc.bytes,
+	}
+	if !fdc.deadline.IsZero() {
+		d := fdc.deadline
+		s.Deadline = &d
+	}
+	if !fdc.evTime.IsZero() {
+		t := fdc.evTime
+		s.LastEvent = &t
+	}
+	if fdc.lat != nil {
+		l := *fdc.lat
+		s.Latency = &l
+	}
+	if fdc.lastErr != nil {
+		s.LastErr = fdc.lastErr.Error()
+	}
+	return s
+}
+
+// State returns a snapshot of the state of File.
+func (f *File) State() FileState {
+	return FileState{
+		Name:   f.name,
+		Fd:     f.fd,
+		Closed: f.isClosed(),
+		Read:   f.r.state(),
+		Write:  f.w.state(),
+	}
+}
+
+// State returns a snapshot of the state of the Files served by Poller,
+// sorted by descriptor.
+func (p *Poller) State() PollerState {
+	files := p.Files()
+	sort.Slice(files, func(i, j int) bool { return files[i].fd < files[j].fd })
+	s := PollerState{Time: time.Now(), Files: make([]FileState, 0, len(files))}
+	for _, f := range files {
+		s.Files = append(s.Files, f.State())
+	}
+	return s
+}
+
+// State returns a snapshot of the state of the default Poller. See
+// Poller.State.
+func State() (PollerState, error) {
+	p, err := defaultPoller()
+	if err != nil {
+		return PollerState{}, err
+	}
+	return p.State(), nil
+}
+
+// DumpState writes the state of Poller, including the stacks of all
+// goroutines (to find out where blocked waiters are), to w as JSON.
+// It's meant for postmortem analysis of wedged I/O.
+func (p *Poller) DumpState(w io.Writer) error {
+	s := p.State()
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			s.Goroutines = string(buf[:n])
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&s)
+}
+
+// DumpState writes the state of the default Poller to w. See
+// Poller.DumpState.
+func DumpState(w io.Writer) error {
+	p, err := defaultPoller()
+	if err != nil {
+		return err
+	}
+	return p.DumpState(w)
+}
+
+// DumpOnPanic writes the state of the default Poller to w if the
+// calling goroutine is panicking, and then keeps panicking. It must be
+// deferred directly:
+//
+//	defer poll.DumpOnPanic(os.Stderr)
+func DumpOnPanic(w io.Writer) {
+	if r := recover(); r != nil {
+		DumpState(w)
+		panic(r)
+	}
+}
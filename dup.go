@@ -0,0 +1,35 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// Dup duplicates the descriptor of File (close-on-exec) and returns it
+// as a new File, served by the same Poller, with the same name and
+// quirks, but its own deadlines, statistics, etc. Both refer to the
+// same open file, so e.g. one subsystem can own reading and another
+// writing; closing one of them doesn't affect the other.
+func (f *File) Dup() (*File, error) {
+	if err := f.Lock(); err != nil {
+		return nil, err
+	}
+	nfd, err := fcntl(f.fd, syscall.F_DUPFD_CLOEXEC, 0)
+	quirks := f.quirks
+	f.Unlock()
+	if err != nil {
+		return nil, f.opError("dup", err)
+	}
+	nf, err := f.p.NewFile(uintptr(nfd), f.name)
+	if err != nil {
+		syscall.Close(nfd)
+		return nil, err
+	}
+	nf.quirks = quirks
+	return nf, nil
+}
@@ -6,6 +6,13 @@
 
 package poll
 
+import (
+	"net"
+	"os"
+	"sync/atomic"
+	"unsafe"
+)
+
 // Error is the type for the errors returned by poller functions and
 // methods. See also the ErrXXX constants.
 type Error int
@@ -13,10 +20,24 @@ type Error int
 // Errors returned by poller functions and methods. In addition to
 // these, poller functions and methods may return the errors reported
 // by the underlying system calls (open(2), read(2), write(2), etc.),
-// as well as io.EOF and io.ErrUnexpectedEOF.
+// as well as io.EOF and io.ErrUnexpectedEOF. Open, Read and Write
+// wrap them in an *OpError; use errors.Is to check for them.
 const (
-	ErrClosed  Error = 1 // Use of closed poller file-descriptor
-	ErrTimeout Error = 2 // Operation timed-out
+	ErrClosed        Error = 1  // Use of closed poller file-descriptor
+	ErrTimeout       Error = 2  // Operation timed-out
+	ErrLocked        Error = 3  // Device locked by another process
+	ErrNoDeadline    Error = 4  // Deadlines not supported
+	ErrTruncated     Error = 5  // Message truncated
+	ErrBusy          Error = 6  // Resource busy
+	ErrBadFd         Error = 7  // Descriptor closed behind File's back
+	ErrHangup        Error = 8  // Device or peer hung up
+	ErrNameInUse     Error = 9  // Name already published
+	ErrTooManyFiles  Error = 10 // Too many Files (see SetMaxFiles)
+	ErrQuotaExceeded Error = 11 // Transfer quota used up (see SetReadQuota)
+	ErrNoDevice      Error = 12 // Device removed (e.g. unplugged)
+	ErrLeaseExpired  Error = 13 // Read lease released or expired
+	ErrBadChecksum   Error = 14 // Frame checksum mismatch (see ChecksumError)
+	ErrWouldBlock    Error = 15 // Operation would block (see TryRead)
 )
 
 // Error returns a string describing the error.
@@ -26,6 +47,32 @@ func (e Error) Error() string {
 		return "use of closed descriptor"
 	case ErrTimeout:
 		return "I/O timeout error"
+	case ErrLocked:
+		return "device locked by another process"
+	case ErrNoDeadline:
+		return "deadline not supported"
+	case ErrTruncated:
+		return "message truncated"
+	case ErrBusy:
+		return "resource busy"
+	case ErrBadFd:
+		return "descriptor closed behind File's back"
+	case ErrHangup:
+		return "hang up"
+	case ErrNameInUse:
+		return "name already in use"
+	case ErrTooManyFiles:
+		return "too many files"
+	case ErrQuotaExceeded:
+		return "quota exceeded"
+	case ErrNoDevice:
+		return "device removed"
+	case ErrLeaseExpired:
+		return "lease expired"
+	case ErrBadChecksum:
+		return "bad checksum"
+	case ErrWouldBlock:
+		return "operation would block"
 	}
 	return "unknown error"
 }
@@ -38,5 +85,101 @@ func (e Error) Timeout() bool {
 // Temporary returns true if the error indicates a temporary condition
 // (re-atempting the operation may succeed).
 func (e Error) Temporary() bool {
-	return e.Timeout()
+	return e.Timeout() || e == ErrWouldBlock
+}
+
+// Is makes errors.Is report ErrTimeout as os.ErrDeadlineExceeded and
+// ErrClosed as net.ErrClosed, so code written for net.Conn and os.File
+// deadline semantics works with Files.
+func (e Error) Is(target error) bool {
+	switch e {
+	case ErrTimeout:
+		return target == os.ErrDeadlineExceeded
+	case ErrClosed:
+		return target == net.ErrClosed
+	}
+	return false
+}
+
+// OpError is the error returned by Open, Read, Write and Seek. It records
+// the operation and the File name along with the underlying error
+// (an Error, a syscall.Errno, io.ErrUnexpectedEOF, etc.). Read returns
+// io.EOF unwrapped. OpErrors wrapping an Error are built once per File
+// and operation, so timeouts and the like don't allocate; they must not
+// be modified.
+type OpError struct {
+	Op   string // "open", "read", "write", "seek", etc.
+	Name string // File name
+	Err  error
+}
+
+// Error returns a string describing the error.
+func (e *OpError) Error() string {
+	return e.Op + " " + e.Name + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout returns true if the underlying error indicates a timeout
+// condition.
+func (e *OpError) Timeout() bool {
+	t, ok := e.Err.(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
+
+// Temporary returns true if the underlying error indicates a temporary
+// condition.
+func (e *OpError) Temporary() bool {
+	t, ok := e.Err.(interface{ Temporary() bool })
+	return ok && t.Temporary()
+}
+
+// opErrOps are the operations whose OpErrors wrapping an Error are
+// pre-built, indexed by opIndex.
+var opErrOps = [...]string{
+	"read", "write", "seek", "accept", "connect", "receive", "send",
+	"splice", "sendfile", "transact", "dup",
+}
+
+// opIndex returns the index of op in opErrOps, -1 if it's not there.
+func opIndex(op string) int {
+	for i, o := range opErrOps {
+		if o == op {
+			return i
+		}
+	}
+	return -1
+}
+
+// opErrors keeps the pre-built OpErrors of a File, indexed by
+// operation and Error. Slots are filled on first use.
+type opErrors [len(opErrOps)][ErrWouldBlock + 1]unsafe.Pointer // *OpError
+
+// opError wraps err, returned by operation op on File, in an
+// *OpError. OpErrors wrapping an Error are pre-built, so returning
+// them doesn't allocate nor lock.
+func (f *File) opError(op string, err error) error {
+	e, ok := err.(Error)
+	i := opIndex(op)
+	if !ok || i < 0 || e < 0 || int(e) >= len(opErrors{}[0]) {
+		return &OpError{Op: op, Name: f.name, Err: err}
+	}
+	tab := (*opErrors)(atomic.LoadPointer(&f.opErrs))
+	if tab == nil {
+		// First error of File. The table may be built concurrently.
+		atomic.CompareAndSwapPointer(&f.opErrs, nil, unsafe.Pointer(new(opErrors)))
+		tab = (*opErrors)(atomic.LoadPointer(&f.opErrs))
+	}
+	slot := &tab[i][e]
+	if oe := atomic.LoadPointer(slot); oe != nil {
+		return (*OpError)(oe)
+	}
+	oe := unsafe.Pointer(&OpError{Op: op, Name: f.name, Err: err})
+	if !atomic.CompareAndSwapPointer(slot, nil, oe) {
+		oe = atomic.LoadPointer(slot)
+	}
+	return (*OpError)(oe)
 }
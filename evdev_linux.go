@@ -0,0 +1,121 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"bytes"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// InputType is the type of an InputEvent.
+type InputType uint16
+
+// Input event types (linux/input-event-codes.h).
+const (
+	InputSyn InputType = 0x00 // Synchronization, ends a batch
+	InputKey InputType = 0x01 // Keys and buttons
+	InputRel InputType = 0x02 // Relative axes
+	InputAbs InputType = 0x03 // Absolute axes
+	InputMsc InputType = 0x04 // Miscellaneous
+	InputSw  InputType = 0x05 // Switches
+	InputLed InputType = 0x11 // LEDs
+)
+
+// inputEvent is struct input_event, whose timeval has the size of a
+// long, so the layout follows the architecture.
+type inputEvent struct {
+	time  syscall.Timeval
+	typ   uint16
+	code  uint16
+	value int32
+}
+
+const inputEventSize = int(unsafe.Sizeof(inputEvent{}))
+
+// inputBufEvents is the number of events read at once by ReadEvents.
+const inputBufEvents = 64
+
+// InputEvent is an event reported by an InputDevice.
+type InputEvent struct {
+	Time  time.Time // Set by the kernel (CLOCK_REALTIME)
+	Type  InputType
+	Code  uint16 // KEY_A, REL_X, ABS_MT_SLOT, etc.
+	Value int32  // Key state (0 up, 1 down, 2 repeat), axis value, etc.
+}
+
+// InputDevice is an evdev input device (keyboard, mouse, joystick,
+// touchscreen, etc.), see OpenInputDevice.
+type InputDevice struct {
+	*File
+	buf []byte
+}
+
+// OpenInputDevice opens the evdev device at path (e.g.
+// "/dev/input/event0") for reading. The device is served by the
+// default Poller, so events can be waited for with deadlines.
+func OpenInputDevice(path string) (*InputDevice, error) {
+	f, err := Open(path, syscall.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	return &InputDevice{File: f, buf: make([]byte, inputBufEvents*inputEventSize)}, nil
+}
+
+// ReadEvents waits, until the read deadline of the device, for events
+// and returns the ones read. Events come in batches ended by an
+// InputSyn event. ReadEvents must not be called concurrently.
+func (d *InputDevice) ReadEvents() ([]InputEvent, error) {
+	// Events are read whole.
+	n, err := d.Read(d.buf)
+	if err != nil {
+		return nil, err
+	}
+	evs := make([]InputEvent, 0, n/inputEventSize)
+	for off := 0; off+inputEventSize <= n; off += inputEventSize {
+		raw := (*inputEvent)(unsafe.Pointer(&d.buf[off]))
+		evs = append(evs, InputEvent{
+			Time:  time.Unix(int64(raw.time.Sec), int64(raw.time.Usec)*1000),
+			Type:  InputType(raw.typ),
+			Code:  raw.code,
+			Value: raw.value,
+		})
+	}
+	return evs, nil
+}
+
+// Grab grabs the device (EVIOCGRAB), so its events are delivered only
+// to this File and not to other readers, such as the console or the
+// display server, or releases it.
+func (d *InputDevice) Grab(grab bool) error {
+	if err := d.Lock(); err != nil {
+		return err
+	}
+	defer d.Unlock()
+	var arg uintptr
+	if grab {
+		arg = 1
+	}
+	return ioctl(d.fd, ioc(iocWrite, 'E', 0x90, unsafe.Sizeof(int32(0))), arg)
+}
+
+// DeviceName returns the name of the device reported by its driver
+// (EVIOCGNAME).
+func (d *InputDevice) DeviceName() (string, error) {
+	if err := d.Lock(); err != nil {
+		return "", err
+	}
+	defer d.Unlock()
+	var name [256]byte
+	if err := ioctl(d.fd, ioc(iocRead, 'E', 0x06, uintptr(len(name))), uintptr(unsafe.Pointer(&name[0]))); err != nil {
+		return "", err
+	}
+	if i := bytes.IndexByte(name[:], 0); i >= 0 {
+		return string(name[:i]), nil
+	}
+	return string(name[:]), nil
+}
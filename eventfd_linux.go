@@ -0,0 +1,67 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// EventFDSemaphore (EFD_SEMAPHORE) makes every Wait decrement the
+// counter by one instead of resetting it.
+const EventFDSemaphore = 0x1
+
+// EventFD is an eventfd(2) counter, a cheap wake-up primitive between
+// goroutines, or processes if the descriptor is shared. Wait honors the
+// read deadline of the File.
+type EventFD struct {
+	*File
+}
+
+// NewEventFD creates an eventfd with counter initval, served by the
+// default Poller. flags may be EventFDSemaphore; the descriptor is
+// always created non-blocking and close-on-exec.
+func NewEventFD(initval uint, flags int) (*EventFD, error) {
+	fd, err := sysEventFD(initval, flags)
+	if err != nil {
+		return nil, err
+	}
+	f, err := NewFile(uintptr(fd), "eventfd")
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return &EventFD{File: f}, nil
+}
+
+// sysEventFD creates a non-blocking close-on-exec eventfd.
+func sysEventFD(initval uint, flags int) (int, error) {
+	fd, _, e := syscall.Syscall(syscall.SYS_EVENTFD2, uintptr(initval),
+		uintptr(flags|syscall.O_CLOEXEC|syscall.O_NONBLOCK), 0)
+	if e != 0 {
+		return -1, e
+	}
+	return int(fd), nil
+}
+
+// Signal adds n to the counter, waking up waiters. It blocks if the
+// counter would overflow, until a Wait makes room.
+func (e *EventFD) Signal(n uint64) error {
+	var buf [8]byte
+	*(*uint64)(unsafe.Pointer(&buf[0])) = n
+	_, err := e.Write(buf[:])
+	return err
+}
+
+// Wait waits until the counter is not zero and returns it, resetting
+// it to zero, or returns 1 and decrements it in EventFDSemaphore mode.
+func (e *EventFD) Wait() (uint64, error) {
+	var buf [8]byte
+	if _, err := e.Read(buf[:]); err != nil {
+		return 0, err
+	}
+	return *(*uint64)(unsafe.Pointer(&buf[0])), nil
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"strings"
+	"time"
+)
+
+// Events is a set of readiness conditions observed on a File.
+type Events int
+
+// Readiness conditions.
+const (
+	Readable   Events = 1 << iota // Data (or end of file) can be read
+	Writable                      // Data can be written
+	Hangup                        // Peer hung up
+	ErrorEvent                    // Error condition pending
+)
+
+// String returns a textual representation of the set, e.g. "Readable|Hangup".
+func (e Events) String() string {
+	var s []string
+	for _, n := range []struct {
+		ev   Events
+		name string
+	}{{Readable, "Readable"}, {Writable, "Writable"}, {Hangup, "Hangup"}, {ErrorEvent, "Error"}} {
+		if e&n.ev != 0 {
+			s = append(s, n.name)
+		}
+	}
+	if len(s) == 0 {
+		return "0"
+	}
+	return strings.Join(s, "|")
+}
+
+// watcher is notified of every event observed on the Files it is
+// attached to. fn is called from the event loop and must not block.
+// An empty event set means the File was closed.
+type watcher struct {
+	fn func(f *File, ev Events)
+}
+
+func (f *File) addWatcher(w *watcher) {
+	f.wm.Lock()
+	ws := make([]*watcher, len(f.watchers), len(f.watchers)+1)
+	copy(ws, f.watchers)
+	f.watchers = append(ws, w)
+	f.wm.Unlock()
+}
+
+func (f *File) removeWatcher(w *watcher) {
+	f.wm.Lock()
+	ws := make([]*watcher, 0, len(f.watchers))
+	for _, x := range f.watchers {
+		if x != w {
+			ws = append(ws, x)
+		}
+	}
+	f.watchers = ws
+	f.wm.Unlock()
+}
+
+// SuppressSpuriousWakeups avoids waking up goroutines which would find
+// File not ready: notifications are skipped if nobody is waiting, and
+// a hang-up which is just a half-close (the peer stopped writing)
+// doesn't wake writers. Set it to false to wake up every waiter on
+// every event. The wake-ups which found File not ready anyway are
+// counted in DirState.Spurious.
+var SuppressSpuriousWakeups = true
+
+// notify is called by the event loops when ev is observed on File. It
+// wakes up the waiters of the affected directions and the watchers.
+func (f *File) notify(ev Events) {
+	if ev&(Readable|Hangup|ErrorEvent) != 0 {
+		f.r.wake(false, f.p.evTime)
+	}
+	if ev&(Writable|Hangup|ErrorEvent) != 0 {
+		f.w.wake(ev&(Writable|ErrorEvent) == 0, f.p.evTime)
+	}
+	f.notifyWatchers(ev)
+}
+
+// wake wakes up the goroutines waiting on fdc. hangup is set if the
+// event is just a hang-up, which is ignored by writers unless it was
+// recorded as a full hang-up (see recordEvents). t is when the loop
+// observed the event, zero if the wake-up doesn't come from the loop.
+func (fdc *fdCtl) wake(hangup bool, t time.Time) {
+	fdc.cond.L.Lock()
+	defer fdc.cond.L.Unlock()
+	if !SuppressSpuriousWakeups {
+		fdc.seq++
+		fdc.setEvTime(t)
+		fdc.cond.Broadcast()
+		return
+	}
+	if hangup && fdc.events&Hangup == 0 {
+		return
+	}
+	fdc.seq++
+	fdc.setEvTime(t)
+	if fdc.waiters > 0 {
+		fdc.cond.Broadcast()
+	}
+}
+
+// LastEvent returns when the event loop last observed File readable
+// and writable (zero if never), taken right after the wait system call
+// returned. Comparing it with the time a Read returns measures the
+// latency between the arrival of data and its handling. Edge-triggered
+// loops (epoll, kqueue) report only the changes of readiness.
+func (f *File) LastEvent() (read, write time.Time) {
+	f.r.cond.L.Lock()
+	read = f.r.evTime
+	f.r.cond.L.Unlock()
+	f.w.cond.L.Lock()
+	write = f.w.evTime
+	f.w.cond.L.Unlock()
+	return read, write
+}
+
+// setEvTime records t, if not zero, as the time of the last event.
+// fdc.cond.L must be held.
+func (fdc *fdCtl) setEvTime(t time.Time) {
+	if !t.IsZero() {
+		fdc.evTime = t
+	}
+}
+
+// recordEvents is called by the event loops which can tell a hang-up
+// or an error condition (not just a half-close) apart. ev is recorded
+// for both directions, so operations finding File not ready fail
+// instead of waiting for an event which may never come. Readiness
+// reported without either condition means it was transient (e.g. the
+// carrier came back) and clears what was recorded, as does a successful
+// transfer (see sysioWait).
+func (f *File) recordEvents(ev Events) {
+	over := ev&(Hangup|ErrorEvent) == 0 && ev&(Readable|Writable) != 0
+	ev &= Hangup | ErrorEvent
+	if ev == 0 && !over {
+		return
+	}
+	for _, fdc := range []*fdCtl{&f.r, &f.w} {
+		fdc.cond.L.Lock()
+		if over {
+			fdc.events &^= Hangup | ErrorEvent
+		} else {
+			fdc.events |= ev
+		}
+		fdc.cond.L.Unlock()
+	}
+}
+
+// pendingError returns the error for the conditions recorded in fdc:
+// the pending socket error for ErrorEvent, or ErrHangup. fdc.cond.L
+// must be held.
+func (f *File) pendingError(fdc *fdCtl) error {
+	if fdc.events&ErrorEvent != 0 {
+		fdc.events &^= ErrorEvent
+		if err := sockError(f.fd); err != nil {
+			return err
+		}
+	}
+	if fdc.events&Hangup != 0 && f.quirks&QuirkSpuriousHangup == 0 {
+		return ErrHangup
+	}
+	return nil
+}
+
+func (f *File) notifyWatchers(ev Events) {
+	f.wm.Lock()
+	ws := f.watchers
+	f.wm.Unlock()
+	for _, w := range ws {
+		w.fn(f, ev)
+	}
+}
@@ -1,5 +1,5 @@
-//go:build linux && !select
-// +build linux,!select
+//go:build linux && !select && !poll
+// +build linux,!select,!poll
 
 // Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
 // Based on Nick Patavalis (npat@efault.net) poller package.
@@ -11,73 +11,152 @@ package poll
 
 import (
 	"log"
-	"sync"
 	"syscall"
+	"time"
+	"unsafe"
 )
 
-var epfd int = -1
-var fdm map[int]*File = map[int]*File{}
-var fdmLock sync.Mutex
+// pollerImpl keeps the epoll specific fields of a Poller.
+type pollerImpl struct {
+	epfd   int
+	wakeFd int         // eventfd(2) signaled by wakeup
+	timers *timerQueue // Set if TimerFD
+}
 
-func init() {
+func (p *Poller) open() error {
 	fd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
 	if err != nil {
-		log.Panicf("poller: EpollCreate1: %s", err.Error())
+		return err
+	}
+	p.epfd = fd
+	// An eventfd takes a single descriptor, and is what Android
+	// recommends, instead of a pipe.
+	p.wakeFd, err = sysEventFD(0, 0)
+	if err == nil {
+		ev := syscall.EpollEvent{
+			Events: syscall.EPOLLIN,
+			Fd:     int32(p.wakeFd)}
+		err = syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, p.wakeFd, &ev)
+	}
+	if err == nil && TimerFD {
+		p.timers, err = newTimerQueue()
+		if err == nil {
+			ev := syscall.EpollEvent{
+				Events: syscall.EPOLLIN,
+				Fd:     int32(p.timers.fd)}
+			err = syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, p.timers.fd, &ev)
+		}
+	}
+	if err != nil {
+		p.release()
+		return err
 	}
-	epfd = fd
-	go evLoop()
+	return nil
+}
+
+// release frees the resources of the event loop.
+func (p *Poller) release() error {
+	var err error
+	if p.wakeFd >= 0 {
+		syscall.Close(p.wakeFd)
+		p.wakeFd = -1
+	}
+	if p.timers != nil {
+		p.timers.close()
+	}
+	if p.epfd >= 0 {
+		err = syscall.Close(p.epfd)
+		p.epfd = -1
+	}
+	return err
+}
+
+func (p *Poller) wakeup() {
+	p.wakeupSent()
+	one := uint64(1)
+	syscall.Write(p.wakeFd, (*[8]byte)(unsafe.Pointer(&one))[:])
 }
 
-func startTrack(fd int, write bool) {} // startTRack non needed in epoll loop
-func stopTrack(fd int, write bool)  {} // stopTRack non needed in epoll loop
+func (p *Poller) startTrack(fd int, write bool) {} // startTrack non needed in epoll loop
+func (p *Poller) stopTrack(fd int, write bool)  {} // stopTrack non needed in epoll loop
+func (p *Poller) deadlineChanged()              {} // Deadlines are handled by File timers
+
+// loopTimer returns a timer served by the loop if TimerFD was set when
+// Poller was created, or nil.
+func (p *Poller) loopTimer(d time.Duration, fn func()) deadlineTimer {
+	if p.timers == nil {
+		return nil
+	}
+	t := &queuedTimer{q: p.timers, fn: fn, index: -1}
+	t.Reset(d)
+	return t
+}
 
-func register(f *File) (err error) {
-	fdmLock.Lock()
-	fdm[f.fd] = f
+func (p *Poller) add(fd int) error {
 	ev := syscall.EpollEvent{
 		Events: syscall.EPOLLIN |
 			syscall.EPOLLOUT |
 			syscall.EPOLLRDHUP |
 			(syscall.EPOLLET & 0xffffffff),
-		Fd: int32(f.fd)}
-	err = syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, f.fd, &ev)
-	fdmLock.Unlock()
-	return
+		Fd: int32(fd)}
+	err := syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, fd, &ev)
+	if err == syscall.EPERM {
+		// Regular files and directories can't be polled, but they
+		// never block either.
+		return nil
+	}
+	return err
 }
 
-func unregister(f *File) (err error) {
-	fdmLock.Lock()
-	delete(fdm, f.fd)
+func (p *Poller) del(fd int) error {
 	var ev syscall.EpollEvent
-	err = syscall.EpollCtl(epfd, syscall.EPOLL_CTL_DEL, f.fd, &ev)
-	fdmLock.Unlock()
-	return
+	return syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_DEL, fd, &ev)
 }
 
-func epollEv(ev *syscall.EpollEvent, write bool) {
-	var fdc *fdCtl
-	fdmLock.Lock()
-	fd := fdm[int(ev.Fd)]
-	fdmLock.Unlock()
+func (p *Poller) epollEv(ev *syscall.EpollEvent) {
+	var events Events
+	if ev.Events&syscall.EPOLLIN != 0 {
+		events |= Readable
+	}
+	if ev.Events&syscall.EPOLLOUT != 0 {
+		events |= Writable
+	}
+	if ev.Events&(syscall.EPOLLRDHUP|syscall.EPOLLHUP) != 0 {
+		events |= Hangup
+	}
+	if ev.Events&syscall.EPOLLERR != 0 {
+		events |= ErrorEvent
+	}
+	fd := p.getFile(int(ev.Fd))
 	if fd == nil {
 		// Drop event. Probably stale FD.
+		p.dropped(int(ev.Fd), events)
 		return
 	}
-	if !write {
-		fdc = &fd.r
+	if ev.Events&(syscall.EPOLLHUP|syscall.EPOLLERR) != 0 {
+		fd.recordEvents(events)
 	} else {
-		fdc = &fd.w
+		// EPOLLRDHUP alone is a half-close, not recorded.
+		fd.recordEvents(events &^ Hangup)
 	}
-	fdc.cond.L.Lock()
-	fdc.cond.Broadcast()
-	fdc.cond.L.Unlock()
+	fd.notify(events)
 }
 
-func evLoop() {
+func (p *Poller) evLoop() {
+	defer close(p.done)
+	var count [8]byte
+	wupFd := int32(p.wakeFd)
+	tmrFd := int32(-1)
+	if p.timers != nil {
+		tmrFd = int32(p.timers.fd)
+	}
 	events := make([]syscall.EpollEvent, 128)
 	for {
-		n, err := syscall.EpollWait(epfd, events, -1)
+		t0 := time.Now()
+		n, err := syscall.EpollWait(p.epfd, events, -1)
+		t1 := p.waited(t0, n)
 		if err != nil {
+			p.waitFailed(err)
 			if err == syscall.EINTR {
 				continue
 			}
@@ -85,17 +164,21 @@ func evLoop() {
 		}
 		for i := 0; i < n; i++ {
 			ev := &events[i]
-			if ev.Events&(syscall.EPOLLIN|
-				syscall.EPOLLRDHUP|
-				syscall.EPOLLHUP|
-				syscall.EPOLLERR) != 0 {
-				epollEv(ev, false)
+			if ev.Fd == wupFd {
+				syscall.Read(p.wakeFd, count[:])
+				p.wakeupRead()
+				if p.quitting() {
+					p.closeErr = p.release()
+					return
+				}
+				continue
 			}
-			if ev.Events&(syscall.EPOLLOUT|
-				syscall.EPOLLHUP|
-				syscall.EPOLLERR) != 0 {
-				epollEv(ev, true)
+			if ev.Fd == tmrFd {
+				p.timers.expire()
+				continue
 			}
+			p.epollEv(ev)
 		}
+		p.dispatched(t1)
 	}
 }
@@ -0,0 +1,159 @@
+//go:build (darwin || dragonfly || freebsd || netbsd || openbsd) && !select && !poll
+// +build darwin dragonfly freebsd netbsd openbsd
+// +build !select
+// +build !poll
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// Based on Nick Patavalis (npat@efault.net) poller package.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"log"
+	"os"
+	"syscall"
+	"time"
+)
+
+// pollerImpl keeps the kqueue specific fields of a Poller.
+type pollerImpl struct {
+	kqfd    int
+	wakeupR *os.File
+	wakeupW *os.File
+}
+
+func (p *Poller) open() error {
+	fd, err := syscall.Kqueue()
+	if err != nil {
+		return err
+	}
+	syscall.CloseOnExec(fd)
+	p.kqfd = fd
+	p.wakeupR, p.wakeupW, err = os.Pipe()
+	if err == nil {
+		err = syscall.SetNonblock(int(p.wakeupR.Fd()), true)
+	}
+	if err == nil {
+		err = syscall.SetNonblock(int(p.wakeupW.Fd()), true)
+	}
+	if err == nil {
+		var ev syscall.Kevent_t
+		syscall.SetKevent(&ev, int(p.wakeupR.Fd()), syscall.EVFILT_READ, syscall.EV_ADD)
+		_, err = syscall.Kevent(p.kqfd, []syscall.Kevent_t{ev}, nil, nil)
+	}
+	if err != nil {
+		p.release()
+		return err
+	}
+	return nil
+}
+
+// release frees the resources of the event loop.
+func (p *Poller) release() error {
+	var err error
+	if p.wakeupR != nil {
+		p.wakeupR.Close()
+		p.wakeupW.Close()
+	}
+	if p.kqfd >= 0 {
+		err = syscall.Close(p.kqfd)
+		p.kqfd = -1
+	}
+	return err
+}
+
+func (p *Poller) wakeup() {
+	p.wakeupSent()
+	p.wakeupW.Write([]byte{0})
+}
+
+func (p *Poller) startTrack(fd int, write bool) {} // startTrack non needed in kqueue loop
+func (p *Poller) stopTrack(fd int, write bool)  {} // stopTrack non needed in kqueue loop
+func (p *Poller) deadlineChanged()              {} // Deadlines are handled by File timers
+
+// loopTimer returns nil: deadlines use time.Timer.
+func (p *Poller) loopTimer(d time.Duration, fn func()) deadlineTimer {
+	return nil
+}
+
+// kqueueCtl applies flags to the read and write filters of fd. The
+// call fails only if both filters are rejected, since some
+// descriptors (e.g. the read end of a pipe) don't support EVFILT_WRITE.
+func (p *Poller) kqueueCtl(fd int, flags int) error {
+	var ev syscall.Kevent_t
+	syscall.SetKevent(&ev, fd, syscall.EVFILT_READ, flags)
+	_, errR := syscall.Kevent(p.kqfd, []syscall.Kevent_t{ev}, nil, nil)
+	syscall.SetKevent(&ev, fd, syscall.EVFILT_WRITE, flags)
+	_, errW := syscall.Kevent(p.kqfd, []syscall.Kevent_t{ev}, nil, nil)
+	if errR != nil && errW != nil {
+		return errR
+	}
+	return nil
+}
+
+func (p *Poller) add(fd int) error {
+	return p.kqueueCtl(fd, syscall.EV_ADD|syscall.EV_CLEAR)
+}
+
+func (p *Poller) del(fd int) error {
+	return p.kqueueCtl(fd, syscall.EV_DELETE)
+}
+
+func (p *Poller) kqueueEv(ev *syscall.Kevent_t) {
+	var events Events
+	switch int(ev.Filter) {
+	case syscall.EVFILT_READ:
+		events = Readable
+	case syscall.EVFILT_WRITE:
+		events = Writable
+	}
+	if ev.Flags&syscall.EV_EOF != 0 {
+		events |= Hangup
+	}
+	if ev.Flags&syscall.EV_ERROR != 0 {
+		events |= ErrorEvent
+	}
+	fd := p.getFile(int(ev.Ident))
+	if fd == nil {
+		// Drop event. Probably stale FD.
+		p.dropped(int(ev.Ident), events)
+		return
+	}
+	fd.notify(events)
+}
+
+func (p *Poller) evLoop() {
+	defer close(p.done)
+	dummy := make([]byte, 1024)
+	wupFd := int(p.wakeupR.Fd())
+	events := make([]syscall.Kevent_t, 128)
+	for {
+		t0 := time.Now()
+		n, err := syscall.Kevent(p.kqfd, nil, events, nil)
+		t1 := p.waited(t0, n)
+		if err != nil {
+			p.waitFailed(err)
+			if err == syscall.EINTR {
+				continue
+			}
+			log.Panicf("poller: Kevent: %s", err.Error())
+		}
+		for i := 0; i < n; i++ {
+			ev := &events[i]
+			if int(ev.Ident) == wupFd {
+				p.wakeupR.Read(dummy)
+				p.wakeupRead()
+				if p.quitting() {
+					p.closeErr = p.release()
+					return
+				}
+				continue
+			}
+			p.kqueueEv(ev)
+		}
+		p.dispatched(t1)
+	}
+}
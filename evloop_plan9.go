@@ -0,0 +1,244 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Plan 9 has no readiness notification (select, poll, epoll, kqueue,
+// etc.) nor non-blocking descriptors, so the event loop is emulated by
+// worker goroutines: a read or write which would have to wait is handed
+// to a worker, which does the blocking system call, and the File is
+// notified, as if the event loop had found it ready, when the call
+// returns. Deadlines and Close don't interrupt the call, but the
+// operation waiting for it: a read given up is completed in the
+// background, and its data is returned by the next read; a write given
+// up may still be done.
+
+// pollerImpl keeps the Plan 9 specific fields of a Poller.
+type pollerImpl struct {
+	wake chan struct{} // Wakes up the loop
+}
+
+// workers keeps the worker state of the descriptors registered with
+// every Poller, by descriptor.
+var workers struct {
+	sync.Mutex
+	m map[int]*fdWorker
+}
+
+// fdWorker is the worker state of a descriptor.
+type fdWorker struct {
+	p   *Poller
+	fd  int
+	m   sync.Mutex
+	dir [2]workerIO // Read and write
+}
+
+// workerIO is a system call handed to a worker.
+type workerIO struct {
+	busy bool   // The worker is doing the call
+	done bool   // Its result is pending delivery
+	buf  []byte // Data, owned by the worker while busy
+	off  int    // Read data already delivered
+	n    int
+	err  error
+	p    *byte // Buffer and size of the write, to match the result
+	size int
+}
+
+func (p *Poller) open() error {
+	p.wake = make(chan struct{}, 1)
+	return nil
+}
+
+// release frees the resources of the event loop.
+func (p *Poller) release() error {
+	return nil
+}
+
+func (p *Poller) wakeup() {
+	p.wakeupSent()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// evLoop just waits to be told to exit, the workers deliver the events.
+func (p *Poller) evLoop() {
+	defer close(p.done)
+	for range p.wake {
+		p.wakeupRead()
+		if p.quitting() {
+			p.closeErr = p.release()
+			return
+		}
+	}
+}
+
+// startTrack is called when an operation has to wait. Unless a worker
+// is already doing the call, which will notify File, File is notified
+// right away: blocking descriptors are always ready (e.g. for RawConn).
+func (p *Poller) startTrack(fd int, write bool) {
+	if w := getWorker(fd); w != nil {
+		w.m.Lock()
+		busy := w.dir[dirIndex(write)].busy
+		w.m.Unlock()
+		if busy {
+			return
+		}
+	}
+	// Called with the cond lock of File held.
+	go p.ready(fd, write)
+}
+
+// ready notifies the File of fd that the direction given by write is
+// ready.
+func (p *Poller) ready(fd int, write bool) {
+	f := p.getFile(fd)
+	if f == nil {
+		return
+	}
+	ev := Readable
+	if write {
+		ev = Writable
+	}
+	f.notify(ev)
+}
+
+func (p *Poller) stopTrack(fd int, write bool) {}
+
+func (p *Poller) deadlineChanged() {} // Deadlines are handled by File timers
+
+// loopTimer returns nil: deadlines use time.Timer.
+func (p *Poller) loopTimer(d time.Duration, fn func()) deadlineTimer {
+	return nil
+}
+
+func (p *Poller) add(fd int) error {
+	workers.Lock()
+	if workers.m == nil {
+		workers.m = map[int]*fdWorker{}
+	}
+	workers.m[fd] = &fdWorker{p: p, fd: fd}
+	workers.Unlock()
+	return nil
+}
+
+// del forgets the workers of fd. A call still in progress completes in
+// the background, without notifying anybody.
+func (p *Poller) del(fd int) error {
+	workers.Lock()
+	delete(workers.m, fd)
+	workers.Unlock()
+	return nil
+}
+
+func getWorker(fd int) *fdWorker {
+	workers.Lock()
+	w := workers.m[fd]
+	workers.Unlock()
+	return w
+}
+
+func dirIndex(write bool) int {
+	if write {
+		return 1
+	}
+	return 0
+}
+
+// sysRead reads from fd into p the data of a completed worker read, if
+// any, or starts one and fails with errAgain.
+func sysRead(fd int, p []byte) (int, error) {
+	w := getWorker(fd)
+	if w == nil || len(p) == 0 {
+		return syscall.Read(fd, p)
+	}
+	w.m.Lock()
+	defer w.m.Unlock()
+	op := &w.dir[0]
+	if op.done {
+		n := copy(p, op.buf[op.off:op.n])
+		op.off += n
+		err := op.err
+		if n > 0 {
+			// The error, if any, is returned by the next call.
+			err = nil
+		}
+		if op.off == op.n && (n == 0 || op.err == nil) {
+			op.done = false
+			op.err = nil
+		}
+		return n, err
+	}
+	if !op.busy {
+		op.busy = true
+		if cap(op.buf) < len(p) {
+			op.buf = make([]byte, len(p))
+		}
+		go w.run(false, op.buf[:len(p)])
+	}
+	return 0, errAgain
+}
+
+// sysWrite returns the result of the completed worker write of p, if
+// any, or starts one and fails with errAgain.
+func sysWrite(fd int, p []byte) (int, error) {
+	w := getWorker(fd)
+	if w == nil || len(p) == 0 {
+		return syscall.Write(fd, p)
+	}
+	w.m.Lock()
+	defer w.m.Unlock()
+	op := &w.dir[1]
+	if op.done {
+		op.done = false
+		if op.p == &p[0] && op.size == len(p) {
+			return op.n, op.err
+		}
+		// The result of a write given up, the data is gone.
+	}
+	if !op.busy {
+		op.busy = true
+		op.p = &p[0]
+		op.size = len(p)
+		op.buf = append(op.buf[:0], p...)
+		go w.run(true, op.buf)
+	}
+	return 0, errAgain
+}
+
+// run does the system call handed to the worker and notifies File.
+func (w *fdWorker) run(write bool, buf []byte) {
+	var n int
+	var err error
+	if write {
+		n, err = syscall.Write(w.fd, buf)
+	} else {
+		n, err = syscall.Read(w.fd, buf)
+	}
+	if n < 0 {
+		n = 0
+	}
+	w.m.Lock()
+	op := &w.dir[dirIndex(write)]
+	op.busy = false
+	op.done = true
+	op.n = n
+	op.err = err
+	if !write {
+		op.off = 0
+	}
+	w.m.Unlock()
+	if getWorker(w.fd) == w {
+		w.p.ready(w.fd, write)
+	}
+}
@@ -0,0 +1,177 @@
+//go:build poll && !select && (linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+// +build poll
+// +build !select
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// Based on Nick Patavalis (npat@efault.net) poller package.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// poll(2) based event loop. Unlike the select(2) loop it has no
+// FD_SETSIZE limit, so any descriptor number can be tracked. Enable it
+// with the "poll" build tag.
+
+// pollerImpl keeps the poll specific fields of a Poller.
+type pollerImpl struct {
+	fdTrR    map[int]struct{}
+	fdTrW    map[int]struct{}
+	fdTrLock sync.Mutex
+	wakeupR  *os.File
+	wakeupW  *os.File
+}
+
+func (p *Poller) open() error {
+	var err error
+	p.fdTrR = map[int]struct{}{}
+	p.fdTrW = map[int]struct{}{}
+	p.wakeupR, p.wakeupW, err = os.Pipe()
+	if err != nil {
+		return err
+	}
+	err = syscall.SetNonblock(int(p.wakeupR.Fd()), true)
+	if err == nil {
+		err = syscall.SetNonblock(int(p.wakeupW.Fd()), true)
+	}
+	if err != nil {
+		p.release()
+		return err
+	}
+	return nil
+}
+
+// release frees the resources of the event loop.
+func (p *Poller) release() error {
+	p.wakeupW.Close()
+	return p.wakeupR.Close()
+}
+
+func (p *Poller) wakeup() {
+	p.wakeupSent()
+	p.wakeupW.Write([]byte{0})
+}
+
+func (p *Poller) startTrack(fd int, write bool) {
+	p.fdTrLock.Lock()
+	last := false
+	if write {
+		_, last = p.fdTrW[fd]
+		p.fdTrW[fd] = struct{}{}
+	} else {
+		_, last = p.fdTrR[fd]
+		p.fdTrR[fd] = struct{}{}
+	}
+	if !last {
+		p.wakeup()
+	}
+	p.fdTrLock.Unlock()
+}
+
+func (p *Poller) stopTrack(fd int, write bool) {
+	p.fdTrLock.Lock()
+	last := false
+	if write {
+		_, last = p.fdTrW[fd]
+		delete(p.fdTrW, fd)
+	} else {
+		_, last = p.fdTrR[fd]
+		delete(p.fdTrR, fd)
+	}
+	if last {
+		p.wakeup()
+	}
+	p.fdTrLock.Unlock()
+}
+
+func (p *Poller) deadlineChanged() {} // Deadlines are handled by File timers
+
+// loopTimer returns nil: deadlines use time.Timer.
+func (p *Poller) loopTimer(d time.Duration, fn func()) deadlineTimer {
+	return nil
+}
+
+func (p *Poller) add(fd int) error {
+	return nil
+}
+
+func (p *Poller) del(fd int) error {
+	p.stopTrack(fd, false)
+	p.stopTrack(fd, true)
+	return nil
+}
+
+func (p *Poller) pollEv(pfd *pollFd) {
+	fd := int(pfd.fd)
+	p.fdTrLock.Lock()
+	if pfd.events == pollOut {
+		delete(p.fdTrW, fd)
+	} else {
+		delete(p.fdTrR, fd)
+	}
+	p.fdTrLock.Unlock()
+	events := pollEvents(pfd.revents)
+	file := p.getFile(fd)
+	if file == nil {
+		// Drop event. Probably stale FD.
+		p.dropped(fd, events)
+		return
+	}
+	file.recordEvents(events)
+	file.notify(events)
+}
+
+func (p *Poller) evLoop() {
+	defer close(p.done)
+	dummy := make([]byte, 1024)
+	wupFd := int(p.wakeupR.Fd())
+	var fds []pollFd
+	for {
+		fds = append(fds[:0], pollFd{fd: int32(wupFd), events: pollIn})
+		p.fdTrLock.Lock()
+		for k := range p.fdTrR {
+			fds = append(fds, pollFd{fd: int32(k), events: pollIn})
+		}
+		for k := range p.fdTrW {
+			fds = append(fds, pollFd{fd: int32(k), events: pollOut})
+		}
+		p.fdTrLock.Unlock()
+		t0 := time.Now()
+		n, err := sysPoll(fds, -1)
+		t1 := p.waited(t0, n)
+		if err != nil {
+			p.waitFailed(err)
+			if err == syscall.EINTR {
+				continue
+			}
+			log.Panicf("poller: poll: %s", err.Error())
+		}
+		if n == 0 {
+			continue
+		}
+		if fds[0].revents != 0 {
+			p.wakeupR.Read(dummy)
+			p.wakeupRead()
+			if p.quitting() {
+				p.closeErr = p.release()
+				return
+			}
+		}
+		for i := 1; i < len(fds); i++ {
+			if fds[i].revents != 0 {
+				p.pollEv(&fds[i])
+			}
+		}
+		p.dispatched(t1)
+	}
+}
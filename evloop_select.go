@@ -1,4 +1,5 @@
-// +build select darwin freebsd dragonfly netbsd openbsd plan9 solaris
+//go:build select
+// +build select
 
 // Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
 // Based on Nick Patavalis (npat@efault.net) poller package.
@@ -9,6 +10,8 @@
 package poll
 
 import (
+	"log"
+	"math/bits"
 	"os"
 	"sync"
 	"syscall"
@@ -16,160 +19,268 @@ import (
 	"unsafe"
 )
 
-var fdTrR map[int]struct{} = map[int]struct{}{}
-var fdTrW map[int]struct{} = map[int]struct{}{}
-var fdTrLock sync.Mutex
-
-var fdm map[int]*File = map[int]*File{}
-var fdmLock sync.Mutex
-var wakeupR *os.File
-var wakeupW *os.File
+// pollerImpl keeps the select specific fields of a Poller.
+type pollerImpl struct {
+	fdTrR    map[int]struct{}
+	fdTrW    map[int]struct{}
+	fdTrLock sync.Mutex
+	wakeupR  *os.File
+	wakeupW  *os.File
+}
 
-func init() {
+func (p *Poller) open() error {
 	var err error
-	wakeupR, wakeupW, err = os.Pipe()
+	p.fdTrR = map[int]struct{}{}
+	p.fdTrW = map[int]struct{}{}
+	p.wakeupR, p.wakeupW, err = os.Pipe()
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
-	err = syscall.SetNonblock(int(wakeupR.Fd()), true)
-	if err != nil {
-		panic(err.Error())
+	err = syscall.SetNonblock(int(p.wakeupR.Fd()), true)
+	if err == nil {
+		err = syscall.SetNonblock(int(p.wakeupW.Fd()), true)
+	}
+	if err == nil && p.wakeupR.Fd() >= FD_SETSIZE {
+		err = syscall.EMFILE
 	}
-	err = syscall.SetNonblock(int(wakeupW.Fd()), true)
 	if err != nil {
-		panic(err.Error())
+		p.release()
+		return err
 	}
-	go evLoop()
+	return nil
 }
 
-func wakeup() {
-	wakeupW.Write([]byte{0})
+// release frees the resources of the event loop.
+func (p *Poller) release() error {
+	p.wakeupW.Close()
+	return p.wakeupR.Close()
 }
 
-func startTrack(fd int, write bool) {
-	fdTrLock.Lock()
+func (p *Poller) wakeup() {
+	p.wakeupSent()
+	p.wakeupW.Write([]byte{0})
+}
+
+func (p *Poller) startTrack(fd int, write bool) {
+	p.fdTrLock.Lock()
 	last := false
 	if write {
-		_, last = fdTrW[fd]
-		fdTrW[fd] = struct{}{}
+		_, last = p.fdTrW[fd]
+		p.fdTrW[fd] = struct{}{}
 	} else {
-		_, last = fdTrR[fd]
-		fdTrR[fd] = struct{}{}
+		_, last = p.fdTrR[fd]
+		p.fdTrR[fd] = struct{}{}
 	}
 	if !last {
-		wakeup()
+		p.wakeup()
 	}
-	fdTrLock.Unlock()
+	p.fdTrLock.Unlock()
 }
 
-func stopTrack(fd int, write bool) {
-	fdTrLock.Lock()
+func (p *Poller) stopTrack(fd int, write bool) {
+	p.fdTrLock.Lock()
 	last := false
 	if write {
-		_, last = fdTrW[fd]
-		delete(fdTrW, fd)
+		_, last = p.fdTrW[fd]
+		delete(p.fdTrW, fd)
 	} else {
-		_, last = fdTrR[fd]
-		delete(fdTrR, fd)
+		_, last = p.fdTrR[fd]
+		delete(p.fdTrR, fd)
 	}
 	if last {
-		wakeup()
+		p.wakeup()
 	}
-	fdTrLock.Unlock()
+	p.fdTrLock.Unlock()
+}
+
+// add rejects the descriptors which don't fit in an FdSet.
+func (p *Poller) add(fd int) error {
+	if fd >= FD_SETSIZE {
+		return syscall.EINVAL
+	}
+	return nil
 }
 
-func register(f *File) error {
-	fdmLock.Lock()
-	fdm[f.fd] = f
-	fdmLock.Unlock()
+// deadlineChanged wakes up the loop to recompute its timeout.
+func (p *Poller) deadlineChanged() {
+	p.wakeup()
+}
+
+// loopTimer returns nil: deadlines use time.Timer.
+func (p *Poller) loopTimer(d time.Duration, fn func()) deadlineTimer {
 	return nil
 }
 
-func unregister(f *File) error {
-	fdmLock.Lock()
-	delete(fdm, f.fd)
-	fdmLock.Unlock()
+func (p *Poller) del(fd int) error {
+	p.stopTrack(fd, false)
+	p.stopTrack(fd, true)
 	return nil
 }
 
-func getFile(fd int) *File {
-	fdmLock.Lock()
-	f := fdm[fd]
-	fdmLock.Unlock()
-	return f
+// expire fires the deadlines of the tracked Files which have expired
+// and returns the time left until the nearest pending one, or -1 if
+// there is none.
+func (p *Poller) expire(fds []int, write bool) time.Duration {
+	timeout := time.Duration(-1)
+	now := time.Now()
+	for _, fd := range fds {
+		f := p.getFile(fd)
+		if f == nil {
+			continue
+		}
+		fdc := &f.r
+		if write {
+			fdc = &f.w
+		}
+		fdc.cond.L.Lock()
+		dl := fdc.deadline
+		if fdc.timeout {
+			dl = time.Time{}
+		}
+		fdc.cond.L.Unlock()
+		if dl.IsZero() {
+			continue
+		}
+		d := dl.Sub(now)
+		if d <= 0 {
+			f.timerEvent(write)
+			continue
+		}
+		if timeout < 0 || d < timeout {
+			timeout = d
+		}
+	}
+	return timeout
+}
+
+// evict stops tracking the descriptors in fds which are no longer
+// valid, failing the operations waiting on their Files. Otherwise
+// select(2) would fail with EBADF forever.
+func (p *Poller) evict(fds []int) {
+	var fdS FdSet
+	for _, fd := range fds {
+		fdS.Reset()
+		fdS.Set(fd)
+		if _, err := Select(fd+1, &fdS, nil, nil, 0); err != syscall.EBADF {
+			continue
+		}
+		p.fdTrLock.Lock()
+		delete(p.fdTrR, fd)
+		delete(p.fdTrW, fd)
+		p.fdTrLock.Unlock()
+		if f := p.getFile(fd); f != nil {
+			f.invalidate()
+		}
+	}
 }
 
-func evLoop() {
+func (p *Poller) evLoop() {
+	defer close(p.done)
 	dummy := make([]byte, 1024)
 	fdR := &FdSet{}
 	fdW := &FdSet{}
-	wupFd := int(wakeupR.Fd())
+	var trR, trW []int
+	wupFd := int(p.wakeupR.Fd())
 	topFd := wupFd
 	for {
 		topFd = wupFd
 		fdR.Reset()
 		fdW.Reset()
 		fdR.Set(wupFd)
-		fdTrLock.Lock()
-		for k, _ := range fdTrR {
+		trR, trW = trR[:0], trW[:0]
+		p.fdTrLock.Lock()
+		for k, _ := range p.fdTrR {
 			fdR.Set(k)
+			trR = append(trR, k)
 			if k > topFd {
 				topFd = k
 			}
 		}
-		for k, _ := range fdTrW {
+		for k, _ := range p.fdTrW {
 			fdW.Set(k)
+			trW = append(trW, k)
 			if k > topFd {
 				topFd = k
 			}
 		}
-		fdTrLock.Unlock()
-		n, err := Select(topFd+1, fdR, fdW, nil, -1)
+		p.fdTrLock.Unlock()
+		// Wait until the nearest deadline of the tracked Files, so
+		// they expire even if their timers are late.
+		timeout := p.expire(trR, false)
+		if d := p.expire(trW, true); d >= 0 && (timeout < 0 || d < timeout) {
+			timeout = d
+		}
+		t0 := time.Now()
+		n, err := Select(topFd+1, fdR, fdW, nil, timeout)
+		t1 := p.waited(t0, n)
+		if err != nil {
+			p.waitFailed(err)
+		}
+		if err == syscall.EBADF {
+			p.evict(append(trR, trW...))
+			continue
+		}
 		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			log.Panicf("poller: Select: %s", err.Error())
+		}
+		if n == 0 {
 			continue
 		}
 		if fdR.IsSet(wupFd) {
 			n--
-			wakeupR.Read(dummy)
+			p.wakeupR.Read(dummy)
+			p.wakeupRead()
+			if p.quitting() {
+				p.closeErr = p.release()
+				return
+			}
 		}
 		for x := 0; x <= topFd && n > 0; x++ {
+			var events Events
 			if fdR.IsSet(x) {
 				n--
-				fdTrLock.Lock()
-				delete(fdTrR, x)
-				fdTrLock.Unlock()
-				file := getFile(x)
-				if file != nil {
-					file.r.cond.L.Lock()
-					file.r.cond.Broadcast()
-					file.r.cond.L.Unlock()
-				}
+				p.fdTrLock.Lock()
+				delete(p.fdTrR, x)
+				p.fdTrLock.Unlock()
+				events |= Readable
 			}
 			if fdW.IsSet(x) {
 				n--
-				fdTrLock.Lock()
-				delete(fdTrW, x)
-				fdTrLock.Unlock()
-				file := getFile(x)
-				if file != nil {
-					file.w.cond.L.Lock()
-					file.w.cond.Broadcast()
-					file.w.cond.L.Unlock()
+				p.fdTrLock.Lock()
+				delete(p.fdTrW, x)
+				p.fdTrLock.Unlock()
+				events |= Writable
+			}
+			if events != 0 {
+				file := p.getFile(x)
+				if file == nil {
+					// Drop event. Probably stale FD.
+					p.dropped(x, events)
+					continue
 				}
+				file.notify(events)
 			}
 		}
+		p.dispatched(t1)
 	}
 }
 
 // Select syscall stuff
 
+// FdSet mirrors the fd_set of the system: an array of fdMask words
+// (C long on Linux, FreeBSD and DragonFly, 32 bits on macOS, NetBSD
+// and OpenBSD), so its layout is right on big-endian and 32-bit
+// targets too.
 const (
-	FD_BITS    = int(unsafe.Sizeof(0) * 8)
+	FD_BITS    = int(unsafe.Sizeof(fdMask(0)) * 8)
 	FD_SETSIZE = 1024
 )
 
 type FdSet struct {
-	bits [FD_SETSIZE / FD_BITS]int
+	bits [FD_SETSIZE / FD_BITS]fdMask
 }
 
 func (fds *FdSet) Reset() {
@@ -179,21 +290,27 @@ func (fds *FdSet) Reset() {
 }
 
 func (fds *FdSet) Set(fd int) {
-	mask := uint(1) << (uint(fd) % uint(FD_BITS))
-
-	fds.bits[fd/FD_BITS] |= int(mask)
+	fds.bits[fd/FD_BITS] |= fdMask(1) << (uint(fd) % uint(FD_BITS))
 }
 
 func (fds *FdSet) UnSet(fd int) {
-	mask := uint(1) << (uint(fd) % uint(FD_BITS))
-
-	fds.bits[fd/FD_BITS] &^= int(mask)
+	fds.bits[fd/FD_BITS] &^= fdMask(1) << (uint(fd) % uint(FD_BITS))
 }
 
 func (fds *FdSet) IsSet(fd int) bool {
-	mask := uint(1) << (uint(fd) % uint(FD_BITS))
+	return fds.bits[fd/FD_BITS]&(fdMask(1)<<(uint(fd)%uint(FD_BITS))) != 0
+}
 
-	return (fds.bits[fd/FD_BITS] & int(mask)) != 0
+// count returns the number of descriptors in the set.
+func (fds *FdSet) count() int {
+	if fds == nil {
+		return 0
+	}
+	n := 0
+	for _, w := range fds.bits {
+		n += bits.OnesCount64(uint64(w))
+	}
+	return n
 }
 
 func Select(n int, r, w, e *FdSet, timeout time.Duration) (int, error) {
@@ -203,7 +320,7 @@ func Select(n int, r, w, e *FdSet, timeout time.Duration) (int, error) {
 
 	if timeout >= 0 {
 		tv := syscall.NsecToTimeval(timeout.Nanoseconds())
-		return syscall.Select(n, rfds, wfds, efds, &tv)
+		return sysSelect(n, rfds, wfds, efds, &tv, r, w, e)
 	}
-	return syscall.Select(n, rfds, wfds, efds, nil)
+	return sysSelect(n, rfds, wfds, efds, nil, r, w, e)
 }
@@ -0,0 +1,129 @@
+//go:build select
+// +build select
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"runtime"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// Descriptors around the word boundaries of both fdMask widths.
+var boundaryFds = []int{0, 1, 31, 32, 33, 63, 64, 65, 127, 128, FD_SETSIZE - 1}
+
+// refFdSet sets fd in a C fd_set of words of width bits, as FD_SET
+// does, and returns its bytes.
+func refFdSet(fd, width int) []byte {
+	if width == 32 {
+		var ref [FD_SETSIZE / 32]uint32
+		ref[fd/32] |= 1 << uint(fd%32)
+		return (*[FD_SETSIZE / 8]byte)(unsafe.Pointer(&ref))[:]
+	}
+	var ref [FD_SETSIZE / 64]uint64
+	ref[fd/64] |= 1 << uint(fd%64)
+	return (*[FD_SETSIZE / 8]byte)(unsafe.Pointer(&ref))[:]
+}
+
+func TestFdSetLayout(t *testing.T) {
+	if unsafe.Sizeof(FdSet{}) != unsafe.Sizeof(syscall.FdSet{}) {
+		t.Fatalf("FdSet is %d bytes, fd_set %d", unsafe.Sizeof(FdSet{}), unsafe.Sizeof(syscall.FdSet{}))
+	}
+	// The fd_set word is a C long, but 32 bits on these systems.
+	width := int(unsafe.Sizeof(uintptr(0)) * 8)
+	switch runtime.GOOS {
+	case "darwin", "netbsd", "openbsd":
+		width = 32
+	}
+	for _, fd := range boundaryFds {
+		var s FdSet
+		s.Set(fd)
+		got := (*[FD_SETSIZE / 8]byte)(unsafe.Pointer(&s))[:]
+		want := refFdSet(fd, width)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Set(%d): byte %d = %#x, want %#x", fd, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestFdSetBoundaries(t *testing.T) {
+	var s FdSet
+	for i, fd := range boundaryFds {
+		s.Set(fd)
+		if !s.IsSet(fd) {
+			t.Errorf("IsSet(%d) = false after Set", fd)
+		}
+		if n := s.count(); n != i+1 {
+			t.Errorf("count = %d after setting %d descriptors", n, i+1)
+		}
+	}
+	for fd := 0; fd < FD_SETSIZE; fd++ {
+		set := false
+		for _, x := range boundaryFds {
+			set = set || x == fd
+		}
+		if s.IsSet(fd) != set {
+			t.Errorf("IsSet(%d) = %v, want %v", fd, !set, set)
+		}
+	}
+	for i, fd := range boundaryFds {
+		s.UnSet(fd)
+		if s.IsSet(fd) {
+			t.Errorf("IsSet(%d) = true after UnSet", fd)
+		}
+		if n := s.count(); n != len(boundaryFds)-i-1 {
+			t.Errorf("count = %d after clearing %d descriptors", n, i+1)
+		}
+	}
+	s.Set(64)
+	s.Reset()
+	if n := s.count(); n != 0 {
+		t.Errorf("count = %d after Reset", n)
+	}
+	var nilSet *FdSet
+	if n := nilSet.count(); n != 0 {
+		t.Errorf("count of nil set = %d", n)
+	}
+}
+
+func TestSelectHighFd(t *testing.T) {
+	var p [2]int
+	if err := syscall.Pipe(p[:]); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(p[0])
+	defer syscall.Close(p[1])
+	for _, fd := range []int{70, 129} {
+		if err := syscall.Dup2(p[0], fd); err != nil {
+			t.Fatal(err)
+		}
+		defer syscall.Close(fd)
+	}
+	var r FdSet
+	r.Set(70)
+	r.Set(129)
+	if n, err := Select(130, &r, nil, nil, 0); err != nil || n != 0 {
+		t.Fatalf("Select on empty pipe = %d, %v", n, err)
+	}
+	if _, err := syscall.Write(p[1], []byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	r.Reset()
+	r.Set(70)
+	r.Set(129)
+	n, err := Select(130, &r, nil, nil, 0)
+	if err != nil || n != 2 {
+		t.Fatalf("Select = %d, %v, want 2", n, err)
+	}
+	if !r.IsSet(70) || !r.IsSet(129) || r.count() != 2 {
+		t.Fatalf("ready set wrong: 70 %v, 129 %v, count %d", r.IsSet(70), r.IsSet(129), r.count())
+	}
+}
@@ -0,0 +1,26 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+// poll(2) event bits. They have the same values on every supported OS.
+const (
+	pollIn   = 0x1
+	pollPri  = 0x2
+	pollOut  = 0x4
+	pollErr  = 0x8
+	pollHup  = 0x10
+	pollNval = 0x20
+)
+
+// pollFd mirrors struct pollfd.
+type pollFd struct {
+	fd      int32
+	events  int16
+	revents int16
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// sysPoll waits for events on fds using ppoll(2), which (unlike
+// poll(2)) is available on every Linux architecture. A negative
+// timeout blocks indefinitely.
+func sysPoll(fds []pollFd, timeout time.Duration) (int, error) {
+	var tsp *syscall.Timespec
+	if timeout >= 0 {
+		ts := syscall.NsecToTimespec(timeout.Nanoseconds())
+		tsp = &ts
+	}
+	var p unsafe.Pointer
+	if len(fds) > 0 {
+		p = unsafe.Pointer(&fds[0])
+	}
+	n, _, e := syscall.Syscall6(syscall.SYS_PPOLL, uintptr(p), uintptr(len(fds)),
+		uintptr(unsafe.Pointer(tsp)), 0, 0, 0)
+	if e != 0 {
+		return 0, e
+	}
+	return int(n), nil
+}
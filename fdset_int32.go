@@ -0,0 +1,12 @@
+//go:build darwin || netbsd || openbsd
+// +build darwin netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+// fdMask is the word of fd_set, 32 bits.
+type fdMask uint32
@@ -0,0 +1,99 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"context"
+	"sync"
+)
+
+// fifoMutex is a mutual exclusion lock granted in FIFO order: Unlock
+// hands the lock over to the goroutine which has been waiting longest.
+// It serializes the operations of each direction of a File, so
+// concurrent readers (or writers) are served in arrival order instead
+// of racing for readiness, and none of them starves. The zero value is
+// an unlocked mutex.
+type fifoMutex struct {
+	m      sync.Mutex
+	locked bool
+	q      []chan struct{} // Waiters, in arrival order
+}
+
+// Lock locks fm, waiting behind the goroutines already waiting.
+func (fm *fifoMutex) Lock() {
+	fm.m.Lock()
+	if !fm.locked {
+		fm.locked = true
+		fm.m.Unlock()
+		return
+	}
+	c := make(chan struct{})
+	fm.q = append(fm.q, c)
+	fm.m.Unlock()
+	<-c // Handed over by Unlock, still locked.
+}
+
+// tryLock locks fm if it's unlocked, reporting whether it did.
+func (fm *fifoMutex) tryLock() bool {
+	fm.m.Lock()
+	defer fm.m.Unlock()
+	if fm.locked {
+		return false
+	}
+	fm.locked = true
+	return true
+}
+
+// lockContext is like Lock, but it gives up waiting when ctx is done,
+// leaving the queue without disturbing the other waiters. It reports
+// whether fm was locked.
+func (fm *fifoMutex) lockContext(ctx context.Context) bool {
+	fm.m.Lock()
+	if !fm.locked {
+		fm.locked = true
+		fm.m.Unlock()
+		return true
+	}
+	c := make(chan struct{})
+	fm.q = append(fm.q, c)
+	fm.m.Unlock()
+	select {
+	case <-c:
+		return true
+	case <-ctx.Done():
+	}
+	fm.m.Lock()
+	for i, x := range fm.q {
+		if x == c {
+			fm.q = append(fm.q[:i], fm.q[i+1:]...)
+			fm.m.Unlock()
+			return false
+		}
+	}
+	fm.m.Unlock()
+	// Handed over meanwhile, pass it on.
+	fm.Unlock()
+	return false
+}
+
+// Unlock unlocks fm, handing it over to the first waiter, if any.
+func (fm *fifoMutex) Unlock() {
+	fm.m.Lock()
+	if !fm.locked {
+		fm.m.Unlock()
+		panic("poll: unlock of unlocked fifoMutex")
+	}
+	if len(fm.q) == 0 {
+		fm.locked = false
+		fm.m.Unlock()
+		return
+	}
+	c := fm.q[0]
+	fm.q[0] = nil
+	fm.q = fm.q[1:]
+	fm.m.Unlock()
+	close(c)
+}
@@ -0,0 +1,53 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// FifoPoll is how often OpenFifoWriter retries the open while the FIFO
+// has no reader.
+var FifoPoll = 10 * time.Millisecond
+
+// OpenFifoWriter opens the FIFO path for writing, waiting until it has
+// a reader: a non-blocking open fails with ENXIO meanwhile. It fails
+// with ErrTimeout if deadline (zero means no deadline) passes first.
+// The File is served by the default Poller.
+func OpenFifoWriter(path string, deadline time.Time) (*File, error) {
+	p, err := defaultPoller()
+	if err != nil {
+		return nil, err
+	}
+	return p.OpenFifoWriter(path, deadline)
+}
+
+// OpenFifoWriter is like the OpenFifoWriter function, returning a File
+// served by Poller.
+func (p *Poller) OpenFifoWriter(path string, deadline time.Time) (*File, error) {
+	for {
+		f, err := p.OpenFile(path, O_WRONLY, 0)
+		if !errors.Is(err, syscall.ENXIO) {
+			return f, err
+		}
+		wait := FifoPoll
+		if !deadline.IsZero() {
+			left := time.Until(deadline)
+			if left <= 0 {
+				return nil, &OpError{Op: "open", Name: path, Err: ErrTimeout}
+			}
+			if left < wait {
+				wait = left
+			}
+		}
+		time.Sleep(wait)
+	}
+}
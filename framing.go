@@ -0,0 +1,252 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+// FrameReader reads the frames of a message oriented protocol from a
+// File, split by a bufio.SplitFunc (e.g. SplitDelim, SplitFixed,
+// SplitLengthPrefix or bufio.ScanLines).
+type FrameReader struct {
+	f     *File
+	split bufio.SplitFunc
+	sum   Checksum // Set by SetChecksum
+	buf   []byte
+	r, w  int // Unsplit data is buf[r:w]
+}
+
+// NewFrameReader returns a FrameReader for f, splitting its data with
+// split, for frames (including delimiters, headers, etc.) up to max
+// bytes (DefaultReaderSize if max <= 0).
+func NewFrameReader(f *File, split bufio.SplitFunc, max int) *FrameReader {
+	if max <= 0 {
+		max = DefaultReaderSize
+	}
+	return &FrameReader{f: f, split: split, buf: make([]byte, max)}
+}
+
+// File returns the File frames are read from.
+func (fr *FrameReader) File() *File {
+	return fr.f
+}
+
+// SetChecksum makes ReadFrame verify the checksum c at the end of
+// every frame, and strip it. Frames whose checksum doesn't match are
+// returned as a *ChecksumError. A nil c disables checking.
+func (fr *FrameReader) SetChecksum(c Checksum) {
+	fr.sum = c
+}
+
+// ReadFrame waits, until the read deadline of File, for the next frame
+// and returns it. The frame is only valid until the next call. When a
+// frame doesn't fit in the buffer, the buffered data is discarded with
+// ErrTruncated. Errors of the split function are returned after
+// consuming the bytes it advanced, so it can skip garbage. ReadFrame
+// must not be called concurrently.
+func (fr *FrameReader) ReadFrame() ([]byte, error) {
+	return fr.ReadFrameContext(context.Background())
+}
+
+// ReadFrameContext is like ReadFrame, but it gives up with ctx.Err()
+// once ctx is done. See ReadContext.
+func (fr *FrameReader) ReadFrameContext(ctx context.Context) ([]byte, error) {
+	frame, err := fr.readFrame(ctx)
+	if err != nil || fr.sum == nil {
+		return frame, err
+	}
+	return VerifyChecksum(fr.sum, frame)
+}
+
+func (fr *FrameReader) readFrame(ctx context.Context) ([]byte, error) {
+	for {
+		if fr.r < fr.w {
+			adv, frame, err := fr.split(fr.buf[fr.r:fr.w], false)
+			if adv > 0 {
+				fr.r += adv
+			}
+			if err != nil {
+				return nil, err
+			}
+			if frame != nil {
+				return frame, nil
+			}
+			if adv > 0 {
+				continue
+			}
+		}
+		if fr.r > 0 {
+			copy(fr.buf, fr.buf[fr.r:fr.w])
+			fr.w -= fr.r
+			fr.r = 0
+		}
+		if fr.w == len(fr.buf) {
+			fr.w = 0
+			return nil, ErrTruncated
+		}
+		n, err := fr.f.ReadContext(ctx, fr.buf[fr.w:])
+		fr.w += n
+		if err == io.EOF && fr.r < fr.w {
+			// Last frame, if any.
+			adv, frame, _ := fr.split(fr.buf[fr.r:fr.w], true)
+			fr.r += adv
+			if frame != nil {
+				return frame, nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// SplitDelim returns a split function for frames ended by delim (e.g.
+// "\r\n"). The frames are returned without delim.
+func SplitDelim(delim []byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.Index(data, delim); i >= 0 {
+			return i + len(delim), data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// SplitFixed returns a split function for frames of n bytes.
+func SplitFixed(n int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if len(data) < n {
+			if atEOF {
+				return len(data), nil, io.ErrUnexpectedEOF
+			}
+			return 0, nil, nil
+		}
+		return n, data[:n], nil
+	}
+}
+
+// SplitLengthPrefix returns a split function for frames preceded by
+// their length, an unsigned integer of size bytes (1, 2 or 4) in byte
+// order order. The frames are returned without the length.
+func SplitLengthPrefix(size int, order binary.ByteOrder) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if len(data) >= size {
+			n := size + int(getUint(data, size, order))
+			if len(data) >= n {
+				return n, data[size:n], nil
+			}
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+}
+
+// getUint decodes an unsigned integer of size bytes (1, 2 or 4).
+func getUint(b []byte, size int, order binary.ByteOrder) uint32 {
+	switch size {
+	case 1:
+		return uint32(b[0])
+	case 2:
+		return uint32(order.Uint16(b))
+	}
+	return order.Uint32(b)
+}
+
+// FrameEncoder appends frame, as sent on the wire, to dst and returns
+// the extended buffer.
+type FrameEncoder func(dst, frame []byte) []byte
+
+// EncodeDelim returns a FrameEncoder ending frames with delim, the
+// counterpart of SplitDelim.
+func EncodeDelim(delim []byte) FrameEncoder {
+	return func(dst, frame []byte) []byte {
+		return append(append(dst, frame...), delim...)
+	}
+}
+
+// EncodeLengthPrefix returns a FrameEncoder preceding frames with their
+// length, the counterpart of SplitLengthPrefix.
+func EncodeLengthPrefix(size int, order binary.ByteOrder) FrameEncoder {
+	return func(dst, frame []byte) []byte {
+		var b [4]byte
+		switch size {
+		case 1:
+			b[0] = uint8(len(frame))
+		case 2:
+			order.PutUint16(b[:], uint16(len(frame)))
+		default:
+			order.PutUint32(b[:], uint32(len(frame)))
+		}
+		return append(append(dst, b[:size]...), frame...)
+	}
+}
+
+// FrameWriter writes the frames of a message oriented protocol to a
+// File, encoded by a FrameEncoder. It's safe for concurrent use; every
+// frame is written with a single Write, so concurrent frames don't get
+// mixed up (unless SetWriteChunk is used).
+type FrameWriter struct {
+	f   *File
+	enc FrameEncoder
+	m   sync.Mutex
+	sum Checksum // Set by SetChecksum
+	buf []byte
+	tmp []byte // Frame and checksum, before encoding
+}
+
+// NewFrameWriter returns a FrameWriter for f, encoding frames with enc.
+// If enc is nil frames are written as they are.
+func NewFrameWriter(f *File, enc FrameEncoder) *FrameWriter {
+	if enc == nil {
+		enc = func(dst, frame []byte) []byte {
+			return append(dst, frame...)
+		}
+	}
+	return &FrameWriter{f: f, enc: enc}
+}
+
+// File returns the File frames are written to.
+func (fw *FrameWriter) File() *File {
+	return fw.f
+}
+
+// SetChecksum makes WriteFrame append the checksum c to every frame,
+// before encoding it. A nil c disables checksums.
+func (fw *FrameWriter) SetChecksum(c Checksum) {
+	fw.m.Lock()
+	fw.sum = c
+	fw.m.Unlock()
+}
+
+// WriteFrame encodes and writes frame. The write deadline of File
+// applies.
+func (fw *FrameWriter) WriteFrame(frame []byte) error {
+	return fw.WriteFrameContext(context.Background(), frame)
+}
+
+// WriteFrameContext is like WriteFrame, but it gives up with ctx.Err()
+// once ctx is done. See WriteContext.
+func (fw *FrameWriter) WriteFrameContext(ctx context.Context, frame []byte) error {
+	fw.m.Lock()
+	defer fw.m.Unlock()
+	if fw.sum != nil {
+		fw.tmp = fw.sum.Append(append(fw.tmp[:0], frame...), frame)
+		frame = fw.tmp
+	}
+	fw.buf = fw.enc(fw.buf[:0], frame)
+	_, err := fw.f.WriteContext(ctx, fw.buf)
+	return err
+}
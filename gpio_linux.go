@@ -0,0 +1,127 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"io"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// GPIO character device ioctls (linux/gpio.h, ABI v1). The encoding is
+// the same on every architecture.
+const (
+	gpioGetLineEvent  = 0xc030b404 // GPIO_GET_LINEEVENT_IOCTL
+	gpioGetLineValues = 0xc040b408 // GPIOHANDLE_GET_LINE_VALUES_IOCTL
+)
+
+const gpioRequestInput = 1 << 0 // GPIOHANDLE_REQUEST_INPUT
+
+// Flags for RequestGPIOEvents.
+const (
+	GPIOActiveLow   = 1 << 2 // Line is active low
+	GPIOPullUp      = 1 << 5 // Enable pull-up bias (Linux 5.5+)
+	GPIOPullDown    = 1 << 6 // Enable pull-down bias (Linux 5.5+)
+	GPIOBiasDisable = 1 << 7 // Disable bias (Linux 5.5+)
+)
+
+// GPIOEdge is a set of signal edges of a GPIO line.
+type GPIOEdge uint32
+
+// GPIO signal edges.
+const (
+	GPIORisingEdge  GPIOEdge = 1 << 0
+	GPIOFallingEdge GPIOEdge = 1 << 1
+	GPIOBothEdges            = GPIORisingEdge | GPIOFallingEdge
+)
+
+// gpioEventRequest is struct gpioevent_request.
+type gpioEventRequest struct {
+	lineOffset  uint32
+	handleFlags uint32
+	eventFlags  uint32
+	consumer    [32]byte
+	fd          int32
+}
+
+// gpioEventSize is the size of struct gpioevent_data, except on 386
+// where it isn't padded and takes 12 bytes.
+const gpioEventSize = 16
+
+// GPIOEvent is an edge detected on a GPIO line.
+type GPIOEvent struct {
+	// Timestamp in nanoseconds, of CLOCK_MONOTONIC since Linux 5.7
+	// and CLOCK_REALTIME before.
+	Timestamp uint64
+	Edge      GPIOEdge // GPIORisingEdge or GPIOFallingEdge
+}
+
+// GPIOLine is a GPIO line event handle, see RequestGPIOEvents.
+type GPIOLine struct {
+	*File
+}
+
+// RequestGPIOEvents requests edge events of line offset of the GPIO
+// chip (e.g. "/dev/gpiochip0") for the given edges. The line is
+// configured as an input with flags (GPIOActiveLow, etc.) and labeled
+// consumer. The returned GPIOLine is served by the default Poller, so
+// edges can be waited for with deadlines.
+func RequestGPIOEvents(chip string, offset int, flags int, edges GPIOEdge, consumer string) (*GPIOLine, error) {
+	cfd, err := syscall.Open(chip, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &OpError{Op: "open", Name: chip, Err: err}
+	}
+	req := gpioEventRequest{
+		lineOffset:  uint32(offset),
+		handleFlags: uint32(flags | gpioRequestInput),
+		eventFlags:  uint32(edges),
+	}
+	copy(req.consumer[:len(req.consumer)-1], consumer)
+	err = ioctl(cfd, gpioGetLineEvent, uintptr(unsafe.Pointer(&req)))
+	syscall.Close(cfd)
+	name := chip + ":" + strconv.Itoa(offset)
+	if err != nil {
+		return nil, &OpError{Op: "open", Name: name, Err: err}
+	}
+	f, err := NewFile(uintptr(req.fd), name)
+	if err != nil {
+		syscall.Close(int(req.fd))
+		return nil, err
+	}
+	return &GPIOLine{File: f}, nil
+}
+
+// ReadEvent waits, until the read deadline of the line, for the next
+// edge.
+func (l *GPIOLine) ReadEvent() (GPIOEvent, error) {
+	var buf [gpioEventSize]byte
+	// Events are read whole.
+	n, err := l.Read(buf[:])
+	if err != nil {
+		return GPIOEvent{}, err
+	}
+	if n < 12 {
+		return GPIOEvent{}, io.ErrUnexpectedEOF
+	}
+	return GPIOEvent{
+		Timestamp: *(*uint64)(unsafe.Pointer(&buf[0])),
+		Edge:      GPIOEdge(*(*uint32)(unsafe.Pointer(&buf[8]))),
+	}, nil
+}
+
+// Value returns the current logical value (0 or 1) of the line.
+func (l *GPIOLine) Value() (int, error) {
+	if err := l.Lock(); err != nil {
+		return 0, err
+	}
+	defer l.Unlock()
+	var values [64]uint8 // struct gpiohandle_data
+	if err := ioctl(l.fd, gpioGetLineValues, uintptr(unsafe.Pointer(&values))); err != nil {
+		return 0, err
+	}
+	return int(values[0]), nil
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// PollerGroup spreads Files over several event loops, so a single loop
+// goroutine doesn't bound the readiness notifications of a process
+// serving many devices. Files are assigned to a loop by descriptor
+// number, or, for groups created by NewPollerGroupOnNodes, to the loop
+// on the NUMA node of their device when it's known.
+type PollerGroup struct {
+	loops []*Poller
+	nodes []int // NUMA node of every loop, nil if not placed
+}
+
+// NewPollerGroup creates a PollerGroup of n event loops, one per CPU
+// (runtime.GOMAXPROCS) if n is not positive.
+func NewPollerGroup(n int) (*PollerGroup, error) {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	g := &PollerGroup{}
+	for i := 0; i < n; i++ {
+		p, err := NewPoller()
+		if err != nil {
+			g.Close()
+			return nil, err
+		}
+		g.loops = append(g.loops, p)
+	}
+	return g, nil
+}
+
+// Len returns the number of event loops of PollerGroup.
+func (g *PollerGroup) Len() int {
+	return len(g.loops)
+}
+
+// Poller returns the i-th event loop of PollerGroup, for Stats,
+// SetMaxFiles, etc.
+func (g *PollerGroup) Poller(i int) *Poller {
+	return g.loops[i]
+}
+
+// PollerFor returns the event loop which serves the descriptor fd of
+// the device file name, which may be empty.
+func (g *PollerGroup) PollerFor(fd uintptr, name string) *Poller {
+	if p := g.localPoller(name); p != nil {
+		return p
+	}
+	return g.loops[fd%uintptr(len(g.loops))]
+}
+
+// NewFile returns a new File, served by one of the loops of
+// PollerGroup, with the given file descriptor and name.
+func (g *PollerGroup) NewFile(fd uintptr, name string) (*File, error) {
+	return g.PollerFor(fd, name).NewFile(fd, name)
+}
+
+// Open is like Poller.Open, for a File served by one of the loops of
+// PollerGroup.
+func (g *PollerGroup) Open(name string, flags int) (*File, error) {
+	return g.OpenFile(name, flags, 0666)
+}
+
+// OpenFile is like Poller.OpenFile, for a File served by one of the
+// loops of PollerGroup.
+func (g *PollerGroup) OpenFile(name string, flags int, perm os.FileMode) (*File, error) {
+	fd, err := sysOpen(name, flags|openFlags(name), perm)
+	if err != nil {
+		return nil, &OpError{Op: "open", Name: name, Err: err}
+	}
+	f, err := g.NewFile(uintptr(fd), name)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, &OpError{Op: "open", Name: name, Err: err}
+	}
+	return f, nil
+}
+
+// NewFromFile is like Poller.NewFromFile, for a File served by one of
+// the loops of PollerGroup.
+func (g *PollerGroup) NewFromFile(of OsFile) (*File, error) {
+	return g.PollerFor(of.Fd(), of.Name()).NewFromFile(of)
+}
+
+// Files returns the Files currently served by PollerGroup.
+func (g *PollerGroup) Files() []*File {
+	var files []*File
+	for _, p := range g.loops {
+		files = append(files, p.Files()...)
+	}
+	return files
+}
+
+// Close closes every loop of PollerGroup (see Poller.Close) and returns
+// the first error.
+func (g *PollerGroup) Close() error {
+	var err error
+	for _, p := range g.loops {
+		if e := p.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPollerGroup(t *testing.T) {
+	g, err := NewPollerGroup(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if g.Len() != 3 {
+		t.Fatalf("Len = %d", g.Len())
+	}
+	for i := 0; i < 4; i++ {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pw.Close()
+		f, err := g.NewFromFile(pr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.p != g.Poller(int(pr.Fd())%g.Len()) {
+			t.Fatalf("fd %d served by the wrong loop", pr.Fd())
+		}
+	}
+	if n := len(g.Files()); n != 4 {
+		t.Fatalf("Files = %d", n)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(g.Files()); n != 0 {
+		t.Fatalf("Files after Close = %d", n)
+	}
+}
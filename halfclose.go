@@ -0,0 +1,53 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// CloseRead shuts down the reading side of File. Further Reads return
+// io.EOF, and the goroutines blocked reading are woken up. Sockets are
+// shut down with shutdown(2). Since the reading side of a read-only
+// File (e.g. the read end of a pipe) is all there is, such File is
+// closed instead, so the writer gets EPIPE.
+func (f *File) CloseRead() error {
+	return f.closeDir(false)
+}
+
+// CloseWrite shuts down the writing side of File, so the peer sees the
+// end of file. Further Writes fail with EPIPE, and the goroutines
+// blocked writing are woken up. Sockets are shut down with shutdown(2).
+// A write-only File (e.g. the write end of a pipe) is closed instead.
+func (f *File) CloseWrite() error {
+	return f.closeDir(true)
+}
+
+func (f *File) closeDir(write bool) error {
+	fdc, how, mode := &f.r, syscall.SHUT_RD, syscall.O_RDONLY
+	if write {
+		fdc, how, mode = &f.w, syscall.SHUT_WR, syscall.O_WRONLY
+	}
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	err := syscall.Shutdown(f.fd, how)
+	if err == syscall.ENOTSOCK {
+		fl, ferr := fcntl(f.fd, syscall.F_GETFL, 0)
+		if ferr == nil && fl&syscall.O_ACCMODE == mode {
+			f.Unlock()
+			return f.Close()
+		}
+		err = nil
+	}
+	if err == nil {
+		fdc.shut = true
+		fdc.cond.Broadcast()
+	}
+	f.Unlock()
+	return err
+}
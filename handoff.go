@@ -0,0 +1,146 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// handoffBatch is the number of descriptors sent per message, below
+// the kernel limit (SCM_MAX_FD is 253 on Linux).
+const handoffBatch = 200
+
+// handoffMsg is the header of a handoff message. Its descriptors are
+// attached as SCM_RIGHTS control data.
+type handoffMsg struct {
+	Files []handoffFile
+	More  bool // Another message follows
+}
+
+// handoffFile describes a File passed by HandoffFiles.
+type handoffFile struct {
+	Name     string
+	Fd       int    // Descriptor number in the sending process
+	LockFile string `json:",omitempty"`
+}
+
+// HandoffFiles sends files over sock, a unix socket connected to
+// another process (typically a re-executed copy of the running binary
+// which got the other end through exec.Cmd.ExtraFiles), which gets
+// them back with ReceiveFiles. The descriptors are duplicated by the
+// kernel, so files are still open when HandoffFiles returns; close them
+// once the receiver has taken over. The lock files created by LockFile
+// are passed to the receiver too.
+func HandoffFiles(sock *File, files []*File) error {
+	for {
+		batch := files
+		if len(batch) > handoffBatch {
+			batch = batch[:handoffBatch]
+		}
+		files = files[len(batch):]
+		msg := handoffMsg{More: len(files) > 0}
+		fds := make([]int, 0, len(batch))
+		for _, f := range batch {
+			if err := f.Lock(); err != nil {
+				return err
+			}
+			msg.Files = append(msg.Files, handoffFile{Name: f.name, Fd: f.fd, LockFile: f.lockF})
+			fds = append(fds, f.fd)
+			f.Unlock()
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		buf := make([]byte, 4+len(data))
+		binary.BigEndian.PutUint32(buf, uint32(len(data)))
+		copy(buf[4:], data)
+		n, err := sock.SendMsg(buf, syscall.UnixRights(fds...), nil)
+		if err == nil && n < len(buf) {
+			_, err = sock.Write(buf[n:])
+		}
+		if err != nil {
+			return err
+		}
+		if !msg.More {
+			return nil
+		}
+	}
+}
+
+// ReceiveFiles receives the Files sent by HandoffFiles over sock and
+// registers them with the default Poller, keeping their names and lock
+// files.
+func ReceiveFiles(sock *File) ([]*File, error) {
+	var files []*File
+	fail := func(err error) ([]*File, error) {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, err
+	}
+	for {
+		var hdr [4]byte
+		oob := make([]byte, syscall.CmsgSpace(handoffBatch*4))
+		n, oobn, _, _, err := sock.RecvMsg(hdr[:], oob)
+		if err == nil && n == 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return fail(err)
+		}
+		fds, err := parseRights(oob[:oobn])
+		if err != nil {
+			closeFds(fds)
+			return fail(err)
+		}
+		if _, err = io.ReadFull(sock, hdr[n:]); err != nil {
+			closeFds(fds)
+			return fail(err)
+		}
+		data := make([]byte, binary.BigEndian.Uint32(hdr[:]))
+		if _, err = io.ReadFull(sock, data); err != nil {
+			closeFds(fds)
+			return fail(err)
+		}
+		var msg handoffMsg
+		if err = json.Unmarshal(data, &msg); err == nil && len(msg.Files) != len(fds) {
+			err = fmt.Errorf("poll: handoff of %d files carried %d descriptors", len(msg.Files), len(fds))
+		}
+		if err != nil {
+			closeFds(fds)
+			return fail(err)
+		}
+		for i, hf := range msg.Files {
+			syscall.CloseOnExec(fds[i])
+			f, err := NewFile(uintptr(fds[i]), hf.Name)
+			if err != nil {
+				closeFds(fds[i:])
+				return fail(err)
+			}
+			if hf.LockFile != "" {
+				// Take over the lock file.
+				if lf, err := os.OpenFile(hf.LockFile, os.O_WRONLY|os.O_TRUNC, 0644); err == nil {
+					fmt.Fprintf(lf, "%10d\n", os.Getpid())
+					lf.Close()
+					f.lockF = hf.LockFile
+				}
+			}
+			files = append(files, f)
+		}
+		if !msg.More {
+			return files, nil
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+// hidMaxDescSize is HID_MAX_DESCRIPTOR_SIZE.
+const hidMaxDescSize = 4096
+
+// hidReportDesc is struct hidraw_report_descriptor.
+type hidReportDesc struct {
+	size  uint32
+	value [hidMaxDescSize]byte
+}
+
+// HIDInfo identifies a HID device.
+type HIDInfo struct {
+	Bus     uint32 // BUS_USB, BUS_BLUETOOTH, etc.
+	Vendor  uint16
+	Product uint16
+}
+
+// HIDDevice is a hidraw device, see OpenHID.
+type HIDDevice struct {
+	*File
+	desc    []byte
+	inSize  int
+	outSize int
+}
+
+// OpenHID opens the hidraw device at path (e.g. "/dev/hidraw0") and
+// reads its report descriptor, which gives the size of its reports.
+// The device is served by the default Poller. Once the device is
+// unplugged its operations fail with ErrNoDevice.
+func OpenHID(path string) (*HIDDevice, error) {
+	f, err := Open(path, syscall.O_RDWR)
+	if err != nil {
+		return nil, err
+	}
+	var size int32
+	err = ioctl(f.fd, ioc(iocRead, 'H', 0x01, 4), uintptr(unsafe.Pointer(&size)))
+	if err != nil {
+		f.Close()
+		return nil, &OpError{Op: "open", Name: path, Err: err}
+	}
+	rd := &hidReportDesc{size: uint32(size)}
+	err = ioctl(f.fd, ioc(iocRead, 'H', 0x02, unsafe.Sizeof(*rd)), uintptr(unsafe.Pointer(rd)))
+	if err != nil {
+		f.Close()
+		return nil, &OpError{Op: "open", Name: path, Err: err}
+	}
+	d := &HIDDevice{File: f, desc: append([]byte(nil), rd.value[:size]...)}
+	d.inSize, d.outSize = hidReportSizes(d.desc)
+	return d, nil
+}
+
+// Info returns the bus type, vendor and product of the device.
+func (d *HIDDevice) Info() (HIDInfo, error) {
+	if err := d.Lock(); err != nil {
+		return HIDInfo{}, err
+	}
+	defer d.Unlock()
+	var info HIDInfo // Same layout as struct hidraw_devinfo.
+	err := ioctl(d.fd, ioc(iocRead, 'H', 0x03, unsafe.Sizeof(info)), uintptr(unsafe.Pointer(&info)))
+	if err != nil {
+		return HIDInfo{}, hidError(err)
+	}
+	return info, nil
+}
+
+// ReportDescriptor returns the report descriptor of the device.
+func (d *HIDDevice) ReportDescriptor() []byte {
+	return d.desc
+}
+
+// InputReportSize returns the size of the largest input report of the
+// device, including the report ID if the device uses them.
+func (d *HIDDevice) InputReportSize() int {
+	return d.inSize
+}
+
+// OutputReportSize returns the size of the largest output report of
+// the device, including the report ID if the device uses them.
+func (d *HIDDevice) OutputReportSize() int {
+	return d.outSize
+}
+
+// ReadReport waits, until the read deadline of the device, for an
+// input report and returns it. Reports start with the report ID if the
+// device uses them.
+func (d *HIDDevice) ReadReport() ([]byte, error) {
+	size := d.inSize
+	if size == 0 {
+		size = hidMaxDescSize
+	}
+	buf := make([]byte, size)
+	n, err := d.Read(buf)
+	if err != nil {
+		return nil, hidError(err)
+	}
+	return buf[:n], nil
+}
+
+// WriteReport sends an output report to the device. Its first byte is
+// the report ID, or 0 if the device doesn't use them. The write
+// deadline applies.
+func (d *HIDDevice) WriteReport(p []byte) error {
+	_, err := d.Write(p)
+	return hidError(err)
+}
+
+// hidError translates the errors of an unplugged device to
+// ErrNoDevice.
+func hidError(err error) error {
+	if errors.Is(err, syscall.EIO) || errors.Is(err, syscall.ENODEV) || errors.Is(err, ErrHangup) {
+		if oe, ok := err.(*OpError); ok {
+			return &OpError{Op: oe.Op, Name: oe.Name, Err: ErrNoDevice}
+		}
+		return ErrNoDevice
+	}
+	return err
+}
+
+// hidReportSizes returns the sizes of the largest input and output
+// reports described by the HID report descriptor desc.
+func hidReportSizes(desc []byte) (in, out int) {
+	type globals struct {
+		size, count, id uint32
+	}
+	var g globals
+	var stack []globals
+	inBits := map[uint32]uint32{}
+	outBits := map[uint32]uint32{}
+	for i := 0; i < len(desc); {
+		prefix := desc[i]
+		if prefix == 0xfe {
+			// Long item.
+			if i+1 >= len(desc) {
+				break
+			}
+			i += 3 + int(desc[i+1])
+			continue
+		}
+		n := int(prefix & 0x03)
+		if n == 3 {
+			n = 4
+		}
+		if i+1+n > len(desc) {
+			break
+		}
+		var v uint32
+		for j := n - 1; j >= 0; j-- {
+			v = v<<8 | uint32(desc[i+1+j])
+		}
+		i += 1 + n
+		switch prefix & 0xfc {
+		case 0x74: // Report Size
+			g.size = v
+		case 0x94: // Report Count
+			g.count = v
+		case 0x84: // Report ID
+			g.id = v
+		case 0xa4: // Push
+			stack = append(stack, g)
+		case 0xb4: // Pop
+			if len(stack) > 0 {
+				g = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case 0x80: // Input
+			inBits[g.id] += g.size * g.count
+		case 0x90: // Output
+			outBits[g.id] += g.size * g.count
+		}
+	}
+	return hidMaxReport(inBits), hidMaxReport(outBits)
+}
+
+// hidMaxReport returns the size in bytes of the largest report, given
+// the bits of every report ID.
+func hidMaxReport(bits map[uint32]uint32) int {
+	max := 0
+	for id, b := range bits {
+		n := int((b + 7) / 8)
+		if id != 0 {
+			n++
+		}
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "sync"
+
+var (
+	hooksLock       sync.RWMutex
+	registerHooks   []func(name string, fd int) error
+	unregisterHooks []func(name string, fd int)
+)
+
+// OnRegister adds fn to the hooks called whenever a File is about to
+// be registered with any Poller (by NewFile, Open, Accept, etc.), with
+// its name and descriptor. If fn returns an error the File is not
+// registered and the function creating it fails with that error, which
+// allows enforcing policies (e.g. denying regular files). Hooks are
+// called in the order they were added, from the goroutine creating the
+// File. If the registration fails after the hooks succeeded, the
+// OnUnregister hooks are called.
+func OnRegister(fn func(name string, fd int) error) {
+	hooksLock.Lock()
+	registerHooks = append(registerHooks, fn)
+	hooksLock.Unlock()
+}
+
+// OnUnregister adds fn to the hooks called whenever a File is
+// unregistered from its Poller, when it's closed. The hooks are called
+// with the File locked, so they must not use it.
+func OnUnregister(fn func(name string, fd int)) {
+	hooksLock.Lock()
+	unregisterHooks = append(unregisterHooks, fn)
+	hooksLock.Unlock()
+}
+
+// runRegisterHooks calls the OnRegister hooks, stopping at the first
+// error.
+func runRegisterHooks(name string, fd int) error {
+	hooksLock.RLock()
+	hooks := registerHooks
+	hooksLock.RUnlock()
+	for _, fn := range hooks {
+		if err := fn(name, fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runUnregisterHooks calls the OnUnregister hooks.
+func runUnregisterHooks(name string, fd int) {
+	hooksLock.RLock()
+	hooks := unregisterHooks
+	hooksLock.RUnlock()
+	for _, fn := range hooks {
+		fn(name, fd)
+	}
+}
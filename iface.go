@@ -0,0 +1,43 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"io"
+	"time"
+)
+
+// Small interfaces satisfied by the deadline aware types of this
+// package: File and Conn implement all of them, Reader the reading
+// ones. Write application code against them so it can be tested with
+// the fakes of package polltest.
+
+// ReaderDeadliner is an io.Reader supporting read deadlines.
+type ReaderDeadliner interface {
+	io.Reader
+	SetReadDeadline(t time.Time) error
+}
+
+// WriterDeadliner is an io.Writer supporting write deadlines.
+type WriterDeadliner interface {
+	io.Writer
+	SetWriteDeadline(t time.Time) error
+}
+
+// ReadWriterDeadliner groups ReaderDeadliner and WriterDeadliner.
+type ReadWriterDeadliner interface {
+	io.ReadWriter
+	DeadlineCarrier
+}
+
+// ReadWriteCloserDeadliner is a ReadWriterDeadliner that can be closed.
+type ReadWriteCloserDeadliner interface {
+	io.ReadWriteCloser
+	DeadlineCarrier
+}
+
+var _ ReadWriteCloserDeadliner = (*File)(nil)
+var _ ReaderDeadliner = (*Reader)(nil)
@@ -0,0 +1,96 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"bytes"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyBufSize is the size of the buffer Events reads into, room
+// for many events and at least one with the longest name.
+const inotifyBufSize = 4096
+
+// InotifyEvent is a filesystem event reported by a Watcher.
+type InotifyEvent struct {
+	Wd     int    // Watch descriptor returned by Watch
+	Mask   uint32 // Event bits (syscall.IN_MODIFY, etc.)
+	Cookie uint32 // Relates the two halves of a rename
+	Name   string // Entry name, for events on a watched directory
+}
+
+// Watcher is an inotify(7) instance served by a Poller, so filesystem
+// events can be waited for along with device I/O, deadlines included.
+type Watcher struct {
+	*File
+	buf []byte
+}
+
+// NewWatcher creates an inotify instance served by the default Poller.
+func NewWatcher() (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	f, err := NewFile(uintptr(fd), "inotify")
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return &Watcher{File: f, buf: make([]byte, inotifyBufSize)}, nil
+}
+
+// Watch adds path to the watched files, or modifies its watch, for
+// the events in mask (syscall.IN_CREATE|syscall.IN_DELETE, etc.) and
+// returns its watch descriptor.
+func (w *Watcher) Watch(path string, mask uint32) (int, error) {
+	if err := w.Lock(); err != nil {
+		return -1, err
+	}
+	defer w.Unlock()
+	wd, err := syscall.InotifyAddWatch(w.fd, path, mask)
+	if err != nil {
+		return -1, &OpError{Op: "watch", Name: path, Err: err}
+	}
+	return wd, nil
+}
+
+// Remove removes the watch wd. An IN_IGNORED event is reported for it.
+func (w *Watcher) Remove(wd int) error {
+	if err := w.Lock(); err != nil {
+		return err
+	}
+	defer w.Unlock()
+	_, err := syscall.InotifyRmWatch(w.fd, uint32(wd))
+	return err
+}
+
+// Events waits, until the read deadline of the Watcher, for events
+// and returns the ones read. Events must not be called concurrently.
+func (w *Watcher) Events() ([]InotifyEvent, error) {
+	n, err := w.Read(w.buf)
+	if err != nil {
+		return nil, err
+	}
+	var evs []InotifyEvent
+	for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+		raw := (*syscall.InotifyEvent)(unsafe.Pointer(&w.buf[off]))
+		off += syscall.SizeofInotifyEvent
+		ev := InotifyEvent{Wd: int(raw.Wd), Mask: raw.Mask, Cookie: raw.Cookie}
+		if raw.Len > 0 {
+			// The name is padded with NULs.
+			name := w.buf[off : off+int(raw.Len)]
+			if i := bytes.IndexByte(name, 0); i >= 0 {
+				name = name[:i]
+			}
+			ev.Name = string(name)
+			off += int(raw.Len)
+		}
+		evs = append(evs, ev)
+	}
+	return evs, nil
+}
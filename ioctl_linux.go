@@ -0,0 +1,12 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+// ioc encodes an ioctl request number like the Linux _IOC macro. The
+// direction bits are architecture dependent.
+func ioc(dir, typ, nr, size uintptr) uint {
+	return uint(dir<<iocDirShift | size<<16 | typ<<8 | nr)
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"encoding/json"
+	"math/bits"
+	"time"
+)
+
+// Histogram buckets: values below histSub nanoseconds get a bucket
+// each, larger ones are bucketed by power of two, each power split in
+// histSub linear sub-buckets. Values from 2^(histMaxExp+1) ns (about
+// 36 minutes) on fall in the last bucket.
+const (
+	histSubBits = 3
+	histSub     = 1 << histSubBits
+	histMaxExp  = 40
+	histBuckets = (histMaxExp - histSubBits + 2) * histSub
+)
+
+// Histogram is a latency histogram with logarithmic buckets (HDR
+// style), whose relative error is below 1/8. The zero value is an
+// empty histogram.
+type Histogram struct {
+	Counts [histBuckets]uint64
+	Total  uint64        // Values recorded
+	Sum    time.Duration // Sum of the values
+	Max    time.Duration // Largest value
+}
+
+// histBucket returns the bucket of v nanoseconds.
+func histBucket(v uint64) int {
+	if v < histSub {
+		return int(v)
+	}
+	e := bits.Len64(v) - 1
+	if e > histMaxExp {
+		return histBuckets - 1
+	}
+	sub := int(v>>uint(e-histSubBits)) & (histSub - 1)
+	return (e-histSubBits+1)*histSub + sub
+}
+
+// histUpper returns the upper bound, in nanoseconds, of bucket i.
+func histUpper(i int) uint64 {
+	if i < histSub {
+		return uint64(i)
+	}
+	e := i/histSub + histSubBits - 1
+	sub := uint64(i % histSub)
+	return (histSub+sub+1)<<uint(e-histSubBits) - 1
+}
+
+// Record adds d to the histogram. Negative durations count as zero.
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.Counts[histBucket(uint64(d))]++
+	h.Total++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+// Mean returns the mean of the recorded values.
+func (h *Histogram) Mean() time.Duration {
+	if h.Total == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Total)
+}
+
+// Quantile returns the value below which the fraction q (0 to 1) of
+// the recorded values fall, e.g. Quantile(0.99) for the 99th
+// percentile.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.Total == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(h.Total) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var n uint64
+	for i, c := range h.Counts {
+		n += c
+		if n >= rank {
+			if v := time.Duration(histUpper(i)); v < h.Max {
+				return v
+			}
+			return h.Max
+		}
+	}
+	return h.Max
+}
+
+// MarshalJSON encodes a summary of the histogram, instead of its
+// buckets.
+func (h Histogram) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Total                    uint64
+		Mean, P50, P90, P99, Max time.Duration
+	}{h.Total, h.Mean(), h.Quantile(0.5), h.Quantile(0.9), h.Quantile(0.99), h.Max})
+}
+
+// LatencyStats are the latency histograms of a direction of a File,
+// see SetLatencyHistograms.
+type LatencyStats struct {
+	// From the loop observing File ready to the retry of the
+	// operation woken up by it.
+	Dispatch Histogram
+	// Duration of the system calls.
+	Syscall Histogram
+}
+
+// SetLatencyHistograms enables, or disables, the latency histograms of
+// File: the dispatch latency of the operations which waited for
+// readiness and the duration of every system call, for soft real-time
+// tuning. Enabling them resets them.
+func (f *File) SetLatencyHistograms(on bool) error {
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	for _, fdc := range []*fdCtl{&f.r, &f.w} {
+		fdc.lat = nil
+		if on {
+			fdc.lat = &LatencyStats{}
+		}
+	}
+	return nil
+}
+
+// Latency returns a copy of the latency histograms of the read and
+// write directions of File, nil if not enabled.
+func (f *File) Latency() (read, write *LatencyStats) {
+	return f.r.latency(), f.w.latency()
+}
+
+func (fdc *fdCtl) latency() *LatencyStats {
+	fdc.cond.L.Lock()
+	defer fdc.cond.L.Unlock()
+	if fdc.lat == nil {
+		return nil
+	}
+	l := *fdc.lat
+	return &l
+}
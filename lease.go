@@ -0,0 +1,78 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"context"
+	"sync"
+)
+
+// ReadLease is exclusive read access to a File, see AcquireReader.
+type ReadLease struct {
+	f      *File
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+// AcquireReader waits, in arrival order, until no other holder has a
+// read lease on File, and returns a new one. The lease is released by
+// Release or, automatically, when ctx is done (e.g. its deadline
+// expires), so a stuck holder doesn't keep the other ones out. ctx
+// also bounds the wait. Leases let several subsystems take turns
+// reading a device; Reads which don't go through a lease aren't
+// excluded.
+func (f *File) AcquireReader(ctx context.Context) (*ReadLease, error) {
+	if !f.lease.lockContext(ctx) {
+		return nil, ctx.Err()
+	}
+	if f.isClosed() {
+		f.lease.Unlock()
+		return nil, ErrClosed
+	}
+	lctx, cancel := context.WithCancel(ctx)
+	l := &ReadLease{f: f, ctx: lctx, cancel: cancel}
+	go func() {
+		<-lctx.Done()
+		l.release()
+	}()
+	return l, nil
+}
+
+// Read reads from the File like File.Read. Once the lease is released
+// or expires it fails with ErrLeaseExpired, interrupting a Read in
+// progress.
+func (l *ReadLease) Read(p []byte) (n int, err error) {
+	if l.ctx.Err() != nil {
+		return 0, l.f.opError("read", ErrLeaseExpired)
+	}
+	n, err = l.f.ReadContext(l.ctx, p)
+	if err != nil && l.ctx.Err() != nil {
+		err = l.f.opError("read", ErrLeaseExpired)
+	}
+	return n, err
+}
+
+// File returns the leased File.
+func (l *ReadLease) File() *File {
+	return l.f
+}
+
+// Done returns a channel closed when the lease is released or expires.
+func (l *ReadLease) Done() <-chan struct{} {
+	return l.ctx.Done()
+}
+
+// Release releases the lease, letting the next waiting AcquireReader
+// in. Releasing an expired lease does nothing.
+func (l *ReadLease) Release() {
+	l.cancel()
+	l.release()
+}
+
+func (l *ReadLease) release() {
+	l.once.Do(l.f.lease.Unlock)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "time"
+
+// LimitPolicy decides what happens when a File, with the given name
+// and descriptor, is about to be registered with a Poller which
+// already serves its maximum number of Files (see SetMaxFiles). If it
+// returns nil the File is registered anyway; otherwise the function
+// creating the File fails with the error returned. Besides the
+// predefined RejectFiles and EvictIdle policies, applications can
+// install their own (e.g. to log the offender or raise an alarm).
+type LimitPolicy func(p *Poller, name string, fd int) error
+
+// RejectFiles is the LimitPolicy which rejects new Files with
+// ErrTooManyFiles.
+func RejectFiles(p *Poller, name string, fd int) error {
+	return ErrTooManyFiles
+}
+
+// EvictIdle is the LimitPolicy which makes room for new Files by
+// closing the File which has been idle for the longest time since its
+// last successful transfer, in either direction (or creation). Files
+// with blocked operations are never evicted; if every File has one
+// ErrTooManyFiles is returned.
+func EvictIdle(p *Poller, name string, fd int) error {
+	var idle *File
+	var idleLast time.Time
+	for _, f := range p.Files() {
+		last, busy := f.activity()
+		if busy {
+			continue
+		}
+		if idle == nil || last.Before(idleLast) {
+			idle, idleLast = f, last
+		}
+	}
+	if idle == nil {
+		return ErrTooManyFiles
+	}
+	idle.Close()
+	return nil
+}
+
+// activity returns the time of the last transfer of File (or its
+// creation), and whether it has blocked operations.
+func (f *File) activity() (last time.Time, busy bool) {
+	for _, fdc := range []*fdCtl{&f.r, &f.w} {
+		fdc.cond.L.Lock()
+		if fdc.last.After(last) {
+			last = fdc.last
+		}
+		busy = busy || fdc.waiters > 0
+		fdc.cond.L.Unlock()
+	}
+	return last, busy
+}
+
+// SetMaxFiles sets the maximum number of Files served by Poller, n <= 0
+// meaning no limit, and the policy applied when a new File would
+// exceed it (RejectFiles if nil). This protects long running daemons
+// from descriptor leaks in plug-in code. The limit is soft: Files
+// registered concurrently may exceed it briefly.
+func (p *Poller) SetMaxFiles(n int, policy LimitPolicy) {
+	if policy == nil {
+		policy = RejectFiles
+	}
+	p.fdmLock.Lock()
+	p.maxFiles = n
+	p.limitPolicy = policy
+	p.fdmLock.Unlock()
+}
+
+// SetMaxFiles sets the maximum number of Files served by the default
+// Poller. See Poller.SetMaxFiles.
+func SetMaxFiles(n int, policy LimitPolicy) error {
+	p, err := defaultPoller()
+	if err != nil {
+		return err
+	}
+	p.SetMaxFiles(n, policy)
+	return nil
+}
+
+// checkLimit applies the LimitPolicy if Poller is full.
+func (p *Poller) checkLimit(name string, fd int) error {
+	p.fdmLock.Lock()
+	full := p.maxFiles > 0 && len(p.fdm) >= p.maxFiles
+	policy := p.limitPolicy
+	p.fdmLock.Unlock()
+	if !full {
+		return nil
+	}
+	return policy(p, name, fd)
+}
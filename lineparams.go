@@ -0,0 +1,38 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+// Parity is the parity mode of a serial line.
+type Parity int
+
+// Parity modes.
+const (
+	ParityNone Parity = iota
+	ParityOdd
+	ParityEven
+	ParityMark
+	ParitySpace
+)
+
+// StopBits is the number of stop bits of a serial line.
+type StopBits int
+
+// Stop bits.
+const (
+	StopBits1 StopBits = iota
+	StopBits2
+	StopBits1Half
+)
+
+// FlowControl is the flow control mode of a serial line.
+type FlowControl int
+
+// Flow control modes.
+const (
+	FlowNone     FlowControl = iota
+	FlowXonXoff              // Software (XON/XOFF) flow control
+	FlowHardware             // Hardware (RTS/CTS) flow control
+)
@@ -0,0 +1,151 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// Accept waits for and accepts a connection on File, a listening
+// socket. The connection is returned as a new File, served by the same
+// Poller and named after the peer address, along with that address.
+// The read deadline of File applies.
+func (f *File) Accept() (*File, syscall.Sockaddr, error) {
+	var nfd int
+	var sa syscall.Sockaddr
+	f.r.m.Lock()
+	_, err := f.sysio(false, func(fd int) (int, error) {
+		for {
+			var err error
+			nfd, sa, err = sysAccept(fd)
+			if err != syscall.ECONNABORTED && err != syscall.EINTR {
+				return 0, err
+			}
+		}
+	})
+	f.r.m.Unlock()
+	if err != nil {
+		return nil, nil, f.opError("accept", err)
+	}
+	name := f.name
+	if a := sockAddr(sa, syscall.SOCK_STREAM); a != nil {
+		name = a.String()
+	}
+	nf, err := f.p.NewFile(uintptr(nfd), name)
+	if err != nil {
+		syscall.Close(nfd)
+		return nil, nil, err
+	}
+	return nf, sa, nil
+}
+
+// sockAddr converts sa into a net.Addr for a socket of type sotype
+// (SOCK_STREAM, SOCK_DGRAM, etc.). It returns nil for unknown address
+// families.
+func sockAddr(sa syscall.Sockaddr, sotype int) net.Addr {
+	switch a := sa.(type) {
+	case *syscall.SockaddrInet4:
+		ip := net.IPv4(a.Addr[0], a.Addr[1], a.Addr[2], a.Addr[3])
+		if sotype == syscall.SOCK_DGRAM {
+			return &net.UDPAddr{IP: ip, Port: a.Port}
+		}
+		return &net.TCPAddr{IP: ip, Port: a.Port}
+	case *syscall.SockaddrInet6:
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, a.Addr[:])
+		zone := ""
+		if a.ZoneId != 0 {
+			zone = strconv.Itoa(int(a.ZoneId))
+		}
+		if sotype == syscall.SOCK_DGRAM {
+			return &net.UDPAddr{IP: ip, Port: a.Port, Zone: zone}
+		}
+		return &net.TCPAddr{IP: ip, Port: a.Port, Zone: zone}
+	case *syscall.SockaddrUnix:
+		switch sotype {
+		case syscall.SOCK_DGRAM:
+			return &net.UnixAddr{Name: a.Name, Net: "unixgram"}
+		case syscall.SOCK_SEQPACKET:
+			return &net.UnixAddr{Name: a.Name, Net: "unixpacket"}
+		}
+		return &net.UnixAddr{Name: a.Name, Net: "unix"}
+	}
+	return nil
+}
+
+// Listener adapts a File holding a listening socket to the
+// net.Listener interface. Accepted connections are returned as *Conn.
+type Listener struct {
+	*File
+	sotype int
+	addr   net.Addr
+}
+
+// NewListener returns a Listener for f, a listening socket.
+func NewListener(f *File) *Listener {
+	l := &Listener{File: f, sotype: syscall.SOCK_STREAM}
+	if err := f.Lock(); err == nil {
+		if t, err := syscall.GetsockoptInt(f.fd, syscall.SOL_SOCKET, syscall.SO_TYPE); err == nil {
+			l.sotype = t
+		}
+		if sa, err := syscall.Getsockname(f.fd); err == nil {
+			l.addr = sockAddr(sa, l.sotype)
+		}
+		f.Unlock()
+	}
+	if l.addr == nil {
+		l.addr = Addr{Net: "poll", Name: f.Name()}
+	}
+	return l
+}
+
+// Accept waits for and returns the next connection. The read deadline
+// of the Listener applies. It implements net.Listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	return l.AcceptConn()
+}
+
+// AcceptConn is like Accept, returning a *Conn.
+func (l *Listener) AcceptConn() (*Conn, error) {
+	f, sa, err := l.File.Accept()
+	if err != nil {
+		return nil, err
+	}
+	var local net.Addr
+	if err := f.Lock(); err == nil {
+		if lsa, err := syscall.Getsockname(f.fd); err == nil {
+			local = sockAddr(lsa, l.sotype)
+		}
+		f.Unlock()
+	}
+	return NewConn(f, local, sockAddr(sa, l.sotype)), nil
+}
+
+// Addr returns the address the Listener is bound to.
+func (l *Listener) Addr() net.Addr {
+	return l.addr
+}
+
+// ListenersFromSystemd returns Listeners for the sockets passed by
+// systemd socket activation. See FilesFromSystemd.
+func ListenersFromSystemd(unsetEnv bool) ([]*Listener, error) {
+	files, err := FilesFromSystemd(unsetEnv)
+	if err != nil {
+		return nil, err
+	}
+	ls := make([]*Listener, len(files))
+	for i, f := range files {
+		ls[i] = NewListener(f)
+	}
+	return ls, nil
+}
+
+var _ net.Listener = (*Listener)(nil)
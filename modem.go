@@ -0,0 +1,99 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ModemLines is a set of modem control lines of a serial port, as
+// returned by ModemStatus.
+type ModemLines int
+
+// Modem control lines.
+const (
+	ModemDTR ModemLines = syscall.TIOCM_DTR // Data Terminal Ready
+	ModemRTS ModemLines = syscall.TIOCM_RTS // Request To Send
+	ModemCTS ModemLines = syscall.TIOCM_CTS // Clear To Send
+	ModemDSR ModemLines = syscall.TIOCM_DSR // Data Set Ready
+	ModemCD  ModemLines = syscall.TIOCM_CD  // Carrier Detect
+	ModemRI  ModemLines = syscall.TIOCM_RI  // Ring Indicator
+)
+
+// ModemStatus returns the state of the modem control lines of the
+// serial port (TIOCMGET). The lines set are asserted.
+func (f *File) ModemStatus() (ModemLines, error) {
+	if err := f.Lock(); err != nil {
+		return 0, err
+	}
+	defer f.Unlock()
+	var bits int32
+	if err := ioctl(f.fd, syscall.TIOCMGET, uintptr(unsafe.Pointer(&bits))); err != nil {
+		return 0, err
+	}
+	return ModemLines(bits), nil
+}
+
+// SetDTR asserts (on) or clears the DTR line of the serial port.
+// Toggling DTR resets boards like the Arduino.
+func (f *File) SetDTR(on bool) error {
+	return f.setModemLines(ModemDTR, on)
+}
+
+// SetRTS asserts (on) or clears the RTS line of the serial port. With
+// hardware flow control (FlowHardware) RTS is driven by the driver.
+func (f *File) SetRTS(on bool) error {
+	return f.setModemLines(ModemRTS, on)
+}
+
+// setModemLines asserts (TIOCMBIS) or clears (TIOCMBIC) lines.
+func (f *File) setModemLines(lines ModemLines, on bool) error {
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	req := uint(syscall.TIOCMBIC)
+	if on {
+		req = syscall.TIOCMBIS
+	}
+	bits := int32(lines)
+	return ioctl(f.fd, req, uintptr(unsafe.Pointer(&bits)))
+}
+
+// DefaultBreak is the duration of the break sent by SendBreak when
+// none is given.
+const DefaultBreak = 250 * time.Millisecond
+
+// SendBreak transmits a break (a continuous stream of zero bits) on the
+// serial port for d, or DefaultBreak if d <= 0, as required by LIN bus
+// and some bootloaders. Writes are held off for the duration of the
+// break; output still pending in the driver isn't waited for.
+func (f *File) SendBreak(d time.Duration) error {
+	if d <= 0 {
+		d = DefaultBreak
+	}
+	f.w.m.Lock()
+	defer f.w.m.Unlock()
+	if err := f.breakCtl(syscall.TIOCSBRK); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	return f.breakCtl(syscall.TIOCCBRK)
+}
+
+// breakCtl starts (TIOCSBRK) or stops (TIOCCBRK) a break.
+func (f *File) breakCtl(req uint) error {
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	return ioctl(f.fd, req, 0)
+}
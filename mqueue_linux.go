@@ -0,0 +1,140 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// mqAttr is struct mq_attr.
+type mqAttr struct {
+	flags    int
+	maxMsg   int
+	msgSize  int
+	curMsgs  int
+	reserved [4]int
+}
+
+// MqAttr are the limits of a message queue created by OpenMqueue.
+type MqAttr struct {
+	MaxMsg  int // Maximum number of messages queued
+	MsgSize int // Maximum message size
+}
+
+// Mqueue is a POSIX message queue, see OpenMqueue.
+type Mqueue struct {
+	*File
+	msgSize int
+}
+
+// mqName returns name as taken by the system calls, without the
+// leading slash.
+func mqName(name string) (*byte, error) {
+	return syscall.BytePtrFromString(strings.TrimPrefix(name, "/"))
+}
+
+// OpenMqueue opens the POSIX message queue name (e.g. "/myqueue") for
+// sending, receiving or both, depending on flags (O_RDONLY, O_WRONLY or
+// O_RDWR, plus O_CREAT and O_EXCL). A queue created with O_CREAT gets
+// permissions perm and the limits in attr, or the system defaults if
+// attr is nil. The queue is served by the default Poller.
+func OpenMqueue(name string, flags int, perm uint32, attr *MqAttr) (*Mqueue, error) {
+	p, err := mqName(name)
+	if err != nil {
+		return nil, err
+	}
+	var a *mqAttr
+	if attr != nil {
+		a = &mqAttr{maxMsg: attr.MaxMsg, msgSize: attr.MsgSize}
+	}
+	// Message queue descriptors are always close-on-exec.
+	fd, _, e := syscall.Syscall6(syscall.SYS_MQ_OPEN, uintptr(unsafe.Pointer(p)),
+		uintptr(flags|syscall.O_NONBLOCK), uintptr(perm), uintptr(unsafe.Pointer(a)), 0, 0)
+	if e != 0 {
+		return nil, &OpError{Op: "open", Name: name, Err: e}
+	}
+	var cur mqAttr
+	_, _, e = syscall.Syscall(syscall.SYS_MQ_GETSETATTR, fd, 0, uintptr(unsafe.Pointer(&cur)))
+	if e != 0 {
+		syscall.Close(int(fd))
+		return nil, &OpError{Op: "open", Name: name, Err: e}
+	}
+	f, err := NewFile(fd, name)
+	if err != nil {
+		syscall.Close(int(fd))
+		return nil, err
+	}
+	return &Mqueue{File: f, msgSize: cur.msgSize}, nil
+}
+
+// UnlinkMqueue removes the message queue name. Open descriptors still
+// work; the queue is destroyed once all of them are closed.
+func UnlinkMqueue(name string) error {
+	p, err := mqName(name)
+	if err != nil {
+		return err
+	}
+	_, _, e := syscall.Syscall(syscall.SYS_MQ_UNLINK, uintptr(unsafe.Pointer(p)), 0, 0)
+	if e != 0 {
+		return &OpError{Op: "unlink", Name: name, Err: e}
+	}
+	return nil
+}
+
+// MsgSize returns the maximum message size of the queue.
+func (mq *Mqueue) MsgSize() int {
+	return mq.msgSize
+}
+
+// Send queues the message p with priority prio (higher priorities are
+// received first), waiting while the queue is full. The write deadline
+// applies.
+func (mq *Mqueue) Send(p []byte, prio uint) error {
+	var ptr unsafe.Pointer
+	if len(p) > 0 {
+		ptr = unsafe.Pointer(&p[0])
+	}
+	mq.w.m.Lock()
+	_, err := mq.sysio(true, func(fd int) (int, error) {
+		_, _, e := syscall.Syscall6(syscall.SYS_MQ_TIMEDSEND, uintptr(fd),
+			uintptr(ptr), uintptr(len(p)), uintptr(prio), 0, 0)
+		if e != 0 {
+			return 0, e
+		}
+		return len(p), nil
+	})
+	mq.w.m.Unlock()
+	if err != nil {
+		return mq.opError("send", err)
+	}
+	return nil
+}
+
+// Receive waits for the oldest message of the highest priority and
+// reads it into p, which must hold at least MsgSize bytes. It returns
+// the message size and priority. The read deadline applies.
+func (mq *Mqueue) Receive(p []byte) (n int, prio uint, err error) {
+	if len(p) < mq.msgSize {
+		return 0, 0, mq.opError("receive", syscall.EMSGSIZE)
+	}
+	var pr uint32
+	mq.r.m.Lock()
+	n, err = mq.sysio(false, func(fd int) (int, error) {
+		r, _, e := syscall.Syscall6(syscall.SYS_MQ_TIMEDRECEIVE, uintptr(fd),
+			uintptr(unsafe.Pointer(&p[0])), uintptr(len(p)), uintptr(unsafe.Pointer(&pr)), 0, 0)
+		if e != 0 {
+			return 0, e
+		}
+		return int(r), nil
+	})
+	mq.r.m.Unlock()
+	if err != nil {
+		return 0, 0, mq.opError("receive", err)
+	}
+	return n, uint(pr), nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"bytes"
+	"strings"
+	"syscall"
+)
+
+// netlinkBufSize is the size of the buffer messages are received into,
+// above the 32KiB the kernel uses for dumps.
+const netlinkBufSize = 64 * 1024
+
+// UeventKernelGroup is the multicast group of the uevents sent by the
+// kernel on NETLINK_KOBJECT_UEVENT sockets.
+const UeventKernelGroup = 1
+
+// NetlinkSocket is an AF_NETLINK socket, see OpenNetlink.
+type NetlinkSocket struct {
+	*File
+	buf []byte
+}
+
+// OpenNetlink opens a netlink socket of protocol proto (e.g.
+// syscall.NETLINK_ROUTE) subscribed to the multicast groups (e.g.
+// 1 for RTMGRP_LINK), so link changes, hotplug events, etc. can be
+// monitored with deadlines. Requests to the kernel can be sent with
+// Write. The socket is served by the default Poller.
+func OpenNetlink(proto int, groups uint32) (*NetlinkSocket, error) {
+	fd, err := sysSocket(syscall.AF_NETLINK, syscall.SOCK_RAW, proto)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups})
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	f, err := NewFile(uintptr(fd), "netlink")
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return &NetlinkSocket{File: f, buf: make([]byte, netlinkBufSize)}, nil
+}
+
+// OpenUevents opens a netlink socket receiving the uevents sent by the
+// kernel (device hotplug, etc.), see ReadUevent.
+func OpenUevents() (*NetlinkSocket, error) {
+	return OpenNetlink(syscall.NETLINK_KOBJECT_UEVENT, UeventKernelGroup)
+}
+
+// recv receives the next datagram into the buffer of the socket.
+func (s *NetlinkSocket) recv() (int, syscall.Sockaddr, error) {
+	n, _, flags, from, err := s.RecvMsg(s.buf, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	if flags&syscall.MSG_TRUNC != 0 {
+		return 0, nil, s.opError("receive", ErrTruncated)
+	}
+	return n, from, nil
+}
+
+// ReadMessages waits, until the read deadline of the socket, for the
+// next datagram and splits it into its messages (nlmsghdr delimited).
+// ReadMessages must not be called concurrently.
+func (s *NetlinkSocket) ReadMessages() ([]syscall.NetlinkMessage, error) {
+	n, _, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(s.buf[:n])
+	if err != nil {
+		return nil, s.opError("read", err)
+	}
+	for i := range msgs {
+		// Don't keep the buffer of the socket.
+		msgs[i].Data = append([]byte(nil), msgs[i].Data...)
+	}
+	return msgs, nil
+}
+
+// Uevent is a kernel uevent.
+type Uevent struct {
+	Action  string            // "add", "remove", "change", "bind", etc.
+	DevPath string            // Device path under /sys
+	Env     map[string]string // SUBSYSTEM, DEVNAME, etc.
+}
+
+// ReadUevent waits, until the read deadline of the socket, for the next
+// uevent sent by the kernel. Messages sent by other processes (e.g.
+// udev) are skipped. ReadUevent must not be called concurrently.
+func (s *NetlinkSocket) ReadUevent() (Uevent, error) {
+	for {
+		n, from, err := s.recv()
+		if err != nil {
+			return Uevent{}, err
+		}
+		if sa, ok := from.(*syscall.SockaddrNetlink); !ok || sa.Pid != 0 {
+			continue
+		}
+		fields := bytes.Split(s.buf[:n], []byte{0})
+		action, devpath, ok := strings.Cut(string(fields[0]), "@")
+		if !ok {
+			continue
+		}
+		ev := Uevent{Action: action, DevPath: devpath, Env: map[string]string{}}
+		for _, kv := range fields[1:] {
+			if k, v, ok := strings.Cut(string(kv), "="); ok {
+				ev.Env[k] = v
+			}
+		}
+		return ev, nil
+	}
+}
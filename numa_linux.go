@@ -0,0 +1,175 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// NewPollerOnNode creates a new Poller whose event loop runs on the
+// CPUs of NUMA node node. Readiness notifications and the OnReadable /
+// OnWritable callbacks then run close to the devices attached to that
+// node (see DeviceNUMANode and InterfaceNUMANode); register their Files
+// with this Poller. The goroutines blocked on those Files are still
+// scheduled anywhere.
+func NewPollerOnNode(node int) (*Poller, error) {
+	cpus, err := nodeCPUs(node)
+	if err != nil {
+		return nil, err
+	}
+	return newPoller(func() error {
+		runtime.LockOSThread()
+		return schedSetaffinity(cpus)
+	})
+}
+
+// DeviceNUMANode returns the NUMA node of the device behind the device
+// file path (e.g. "/dev/ttyS0"), or -1 if it isn't bound to any node.
+func DeviceNUMANode(path string) (int, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return -1, err
+	}
+	kind := "char"
+	switch st.Mode & syscall.S_IFMT {
+	case syscall.S_IFCHR:
+	case syscall.S_IFBLK:
+		kind = "block"
+	default:
+		return -1, syscall.ENODEV
+	}
+	dev := uint64(st.Rdev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	return readNUMANode(fmt.Sprintf("/sys/dev/%s/%d:%d/device/numa_node", kind, major, minor))
+}
+
+// InterfaceNUMANode returns the NUMA node of the network interface
+// named ifname, or -1 if it isn't bound to any node.
+func InterfaceNUMANode(ifname string) (int, error) {
+	return readNUMANode("/sys/class/net/" + ifname + "/device/numa_node")
+}
+
+func readNUMANode(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return -1, nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// NewPollerGroupOnNodes creates a PollerGroup with one event loop per
+// NUMA node in nodes (every online node if none is given), running on
+// the CPUs of its node (see NewPollerOnNode). Files of devices bound to
+// one of those nodes (see DeviceNUMANode) are served by its loop, the
+// rest are assigned by descriptor number.
+func NewPollerGroupOnNodes(nodes ...int) (*PollerGroup, error) {
+	if len(nodes) == 0 {
+		data, err := os.ReadFile("/sys/devices/system/node/online")
+		if err != nil {
+			return nil, err
+		}
+		if nodes, err = parseList(string(data)); err != nil {
+			return nil, err
+		}
+	}
+	g := &PollerGroup{}
+	for _, node := range nodes {
+		p, err := NewPollerOnNode(node)
+		if err != nil {
+			g.Close()
+			return nil, err
+		}
+		g.loops = append(g.loops, p)
+		g.nodes = append(g.nodes, node)
+	}
+	return g, nil
+}
+
+// localPoller returns the loop of PollerGroup on the NUMA node of the
+// device file name, or nil if there is none.
+func (g *PollerGroup) localPoller(name string) *Poller {
+	if g.nodes == nil || name == "" {
+		return nil
+	}
+	node, err := DeviceNUMANode(name)
+	if err != nil || node < 0 {
+		return nil
+	}
+	for i, n := range g.nodes {
+		if n == node {
+			return g.loops[i]
+		}
+	}
+	return nil
+}
+
+// nodeCPUs returns the CPUs of NUMA node node.
+func nodeCPUs(node int) ([]int, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/sys/devices/system/node/node%d/cpulist", node))
+	if err != nil {
+		return nil, err
+	}
+	return parseList(string(data))
+}
+
+// parseList parses a sysfs list of CPUs or nodes, like "0-3,8-11".
+func parseList(list string) ([]int, error) {
+	var cpus []int
+	for _, r := range strings.Split(strings.TrimSpace(list), ",") {
+		if r == "" {
+			continue
+		}
+		lo, hi := r, r
+		if i := strings.IndexByte(r, '-'); i >= 0 {
+			lo, hi = r[:i], r[i+1:]
+		}
+		a, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, err
+		}
+		b, err := strconv.Atoi(hi)
+		if err != nil {
+			return nil, err
+		}
+		for c := a; c <= b; c++ {
+			cpus = append(cpus, c)
+		}
+	}
+	if len(cpus) == 0 {
+		return nil, syscall.EINVAL
+	}
+	return cpus, nil
+}
+
+// schedSetaffinity binds the calling thread to cpus.
+func schedSetaffinity(cpus []int) error {
+	max := 0
+	for _, c := range cpus {
+		if c > max {
+			max = c
+		}
+	}
+	mask := make([]uint64, max/64+1)
+	for _, c := range cpus {
+		mask[c/64] |= 1 << uint(c%64)
+	}
+	_, _, e := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0,
+		uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
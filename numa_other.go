@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+// localPoller returns nil, NUMA placement is only supported on Linux.
+func (g *PollerGroup) localPoller(name string) *Poller {
+	return nil
+}
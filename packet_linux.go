@@ -0,0 +1,117 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// PacketAddr is a link layer address (struct sockaddr_ll).
+type PacketAddr struct {
+	Protocol uint16           // EtherType (e.g. syscall.ETH_P_IP), host byte order
+	Ifindex  int              // Interface index
+	Hatype   uint16           // ARP hardware type (received packets only)
+	Pkttype  uint8            // syscall.PACKET_HOST, etc. (received packets only)
+	Addr     net.HardwareAddr // Hardware address
+}
+
+// PacketSocket is an AF_PACKET socket, see OpenPacket.
+type PacketSocket struct {
+	*File
+	ifindex int
+	proto   uint16
+}
+
+// htons converts v between host and network byte order.
+func htons(v uint16) uint16 {
+	b := [2]byte{byte(v >> 8), byte(v)}
+	return *(*uint16)(unsafe.Pointer(&b[0]))
+}
+
+// OpenPacket opens an AF_PACKET socket bound to the network interface
+// ifname (e.g. "eth0"), receiving the packets of EtherType proto
+// (syscall.ETH_P_ALL for all of them). Packets are read and written
+// whole, with their link layer header, unless cooked is set, in which
+// case the kernel strips and builds it from the PacketAddr. The socket
+// is served by the default Poller.
+func OpenPacket(ifname string, proto uint16, cooked bool) (*PacketSocket, error) {
+	ifi, err := net.InterfaceByName(ifname)
+	if err != nil {
+		return nil, err
+	}
+	typ := syscall.SOCK_RAW
+	if cooked {
+		typ = syscall.SOCK_DGRAM
+	}
+	// Don't receive anything before being bound to the interface.
+	s, err := sysSocket(syscall.AF_PACKET, typ, 0)
+	if err != nil {
+		return nil, &OpError{Op: "open", Name: ifname, Err: err}
+	}
+	err = syscall.Bind(s, &syscall.SockaddrLinklayer{Protocol: htons(proto), Ifindex: ifi.Index})
+	if err != nil {
+		syscall.Close(s)
+		return nil, &OpError{Op: "open", Name: ifname, Err: err}
+	}
+	f, err := NewFile(uintptr(s), ifname)
+	if err != nil {
+		syscall.Close(s)
+		return nil, err
+	}
+	return &PacketSocket{File: f, ifindex: ifi.Index, proto: proto}, nil
+}
+
+// ReadPacket waits, until the read deadline of the socket, for the next
+// packet and reads it into p, returning its size and link layer
+// address. If the packet doesn't fit in p, the first len(p) bytes are
+// returned, the rest is discarded and the error is ErrTruncated.
+func (s *PacketSocket) ReadPacket(p []byte) (n int, from PacketAddr, err error) {
+	// RecvMsg can't be used, packet sockets reject MSG_CMSG_CLOEXEC.
+	n, sa, err := s.ReadFromAddr(p)
+	if err != nil && err != ErrTruncated {
+		return 0, PacketAddr{}, err
+	}
+	if ll, ok := sa.(*syscall.SockaddrLinklayer); ok {
+		from = PacketAddr{
+			Protocol: htons(ll.Protocol),
+			Ifindex:  ll.Ifindex,
+			Hatype:   ll.Hatype,
+			Pkttype:  ll.Pkttype,
+			Addr:     append(net.HardwareAddr(nil), ll.Addr[:ll.Halen]...),
+		}
+	}
+	if err != nil {
+		return n, from, s.opError("receive", err)
+	}
+	return n, from, nil
+}
+
+// WritePacket sends the packet p to the address to, waiting until the
+// write deadline of the socket if the interface queue is full. A zero
+// Ifindex or Protocol in to stands for those of the socket. Raw sockets
+// may pass a zero PacketAddr, as p holds the link layer header; cooked
+// ones must set the destination hardware address.
+func (s *PacketSocket) WritePacket(p []byte, to PacketAddr) error {
+	sa := &syscall.SockaddrLinklayer{
+		Protocol: htons(to.Protocol),
+		Ifindex:  to.Ifindex,
+		Halen:    uint8(len(to.Addr)),
+	}
+	if to.Protocol == 0 {
+		sa.Protocol = htons(s.proto)
+	}
+	if sa.Ifindex == 0 {
+		sa.Ifindex = s.ifindex
+	}
+	if len(to.Addr) > len(sa.Addr) {
+		return s.opError("write", syscall.EINVAL)
+	}
+	copy(sa.Addr[:], to.Addr)
+	_, err := s.WriteToAddr(p, sa)
+	return err
+}
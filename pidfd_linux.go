@@ -0,0 +1,52 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"context"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// PidFD is a process file descriptor, see OpenPidFD.
+type PidFD struct {
+	*File
+	pid int
+}
+
+// OpenPidFD returns a pidfd(2) for process pid, served by the default
+// Poller, which becomes readable when the process exits, so child exits
+// can be waited for along with other I/O. It requires Linux 5.3+.
+func OpenPidFD(pid int) (*PidFD, error) {
+	// pidfd_open always returns a close-on-exec descriptor.
+	fd, _, e := syscall.Syscall(sysPidfdOpen, uintptr(pid), 0, 0)
+	if e != 0 {
+		return nil, e
+	}
+	f, err := NewFile(fd, "pidfd:"+strconv.Itoa(pid))
+	if err != nil {
+		syscall.Close(int(fd))
+		return nil, err
+	}
+	return &PidFD{File: f, pid: pid}, nil
+}
+
+// Pid returns the process ID.
+func (p *PidFD) Pid() int {
+	return p.pid
+}
+
+// WaitExit waits until the process exits, or returns ErrTimeout at
+// deadline (zero means no deadline). It sets the read deadline of the
+// PidFD. A child process is left to be reaped (e.g. with os.Process.Wait
+// or syscall.Wait4), which no longer blocks.
+func (p *PidFD) WaitExit(deadline time.Time) error {
+	if err := p.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+	return p.WaitRead(context.Background())
+}
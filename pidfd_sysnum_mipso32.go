@@ -0,0 +1,13 @@
+//go:build linux && (mips || mipsle)
+// +build linux
+// +build mips mipsle
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+// sysPidfdOpen is the pidfd_open(2) system call number (o32 ABI).
+const sysPidfdOpen = 4434
@@ -0,0 +1,63 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"io"
+	"syscall"
+)
+
+// ReadAt reads len(p) bytes from File starting at byte offset off,
+// using pread(2). It implements io.ReaderAt: it returns a non-nil
+// error when n < len(p), io.EOF at end of file. ReadAt doesn't use nor
+// change the file offset, and doesn't serialize with Read, so it can
+// be used by concurrent readers. The read deadline applies.
+func (f *File) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, f.opError("read", syscall.EINVAL)
+	}
+	for n < len(p) {
+		var nn int
+		nn, err = f.sysio(false, func(fd int) (int, error) {
+			return syscall.Pread(fd, p[n:], off+int64(n))
+		})
+		if err == nil && nn == 0 {
+			return n, io.EOF
+		}
+		n += nn
+		if err != nil {
+			return n, f.opError("read", err)
+		}
+	}
+	return n, nil
+}
+
+// WriteAt writes len(p) bytes to File starting at byte offset off,
+// using pwrite(2). It implements io.WriterAt: it returns a non-nil
+// error when n < len(p). WriteAt doesn't use nor change the file
+// offset. The write deadline applies.
+func (f *File) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, f.opError("write", syscall.EINVAL)
+	}
+	for n < len(p) {
+		var nn int
+		nn, err = f.sysio(true, func(fd int) (int, error) {
+			return syscall.Pwrite(fd, p[n:], off+int64(n))
+		})
+		if err == nil && nn == 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		n += nn
+		if err != nil {
+			return n, f.opError("write", err)
+		}
+	}
+	return n, nil
+}
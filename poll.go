@@ -7,10 +7,14 @@
 package poll
 
 import (
+	"context"
 	"io"
+	"os"
+	"runtime"
 	"sync"
 	"syscall"
 	"time"
+	"unsafe"
 )
 
 const (
@@ -18,19 +22,50 @@ const (
 	O_WRONLY   int = syscall.O_WRONLY   // open the file write-only.
 	O_RDWR     int = syscall.O_RDWR     // open the file read-write.
 	O_NONBLOCK int = syscall.O_NONBLOCK // open in non block mode.
+	O_APPEND   int = syscall.O_APPEND   // append data to the file when writing.
+	O_CREATE   int = syscall.O_CREAT    // create a new file if none exists.
+	O_EXCL     int = syscall.O_EXCL     // used with O_CREATE, file must not exist.
+	O_TRUNC    int = syscall.O_TRUNC    // truncate regular writable file when opened.
 )
 
 // fdCtl keeps control fields (locks, timers, etc) for a single
 // direction. For every File there is one fdCtl for Read operations and
 // another for Write operations.
 type fdCtl struct {
-	m        sync.Mutex
+	m        fifoMutex // Serializes operations, in arrival order.
 	cond     *sync.Cond
 	deadline time.Time
-	timer    *time.Timer
+	timer    deadlineTimer
+	fire     time.Time // When timer fires, zero if stopped.
 	timeout  bool
+	waiters  int                    // Goroutines waiting for readiness.
+	bytes    uint64                 // Bytes transferred.
+	last     time.Time              // Last transfer, or File creation.
+	lastErr  error                  // Last error returned by the system, other than EAGAIN.
+	hook     func(n int, err error) // Called with the result of every attempt.
+	shut     bool                   // Direction shut down by CloseRead or CloseWrite.
+	events   Events                 // Hangup and ErrorEvent conditions reported by the loop.
+	seq      uint64                 // Incremented by every readiness notification.
+	evTime   time.Time              // When the loop observed the last notification.
+	spurious uint64                 // Wake-ups which found the descriptor not ready.
+	chunk    int                    // Set by SetWriteChunk. Guarded by m.
+	busy     *busyPoll              // Set by SetBusyPoll.
+	quota    *quota                 // Set by SetReadQuota and SetWriteQuota.
+	lat      *LatencyStats          // Set by SetLatencyHistograms.
 }
 
+// DeadlineSlack is how late a deadline may expire to save re-arming
+// its timer when the deadline is moved earlier. Moving a deadline later
+// never re-arms the timer.
+var DeadlineSlack time.Duration
+
+// TimerFD makes the Pollers created afterwards multiplex the deadline
+// timers of their Files on a timerfd(2) served by the event loop,
+// instead of using a time.Timer per deadline. This saves timer
+// goroutine churn and improves deadline accuracy under load. It's only
+// supported by the epoll backend (Linux) and ignored elsewhere.
+var TimerFD bool
+
 // OsFile interface with *os.File methods used in NewFromFile
 type OsFile interface {
 	Close() error
@@ -40,50 +75,81 @@ type OsFile interface {
 
 // File is an *os.File like object who adds polling capabilities
 type File struct {
-	closed bool // Set by Close(), never cleared
+	closed bool         // Set by Close(), never cleared
+	bad    bool         // Set by invalidate(), never cleared
+	io     sync.RWMutex // Read locked by in-flight syscalls, write locked by Lock
 	fd     int
 	name   string
 	closeF func() error
+	lockF  string // Lock file created by LockFile, removed on Close
+	p      *Poller
+	quirks Quirks    // Guarded by Lock
+	lease  fifoMutex // Held by the ReadLease holder, see AcquireReader
+	// Set by Publish. Guarded by registryLock
+	pubName string
+	opErrs  unsafe.Pointer // *opErrors, pre-built OpErrors. Atomic access
+	// Guarded by wm
+	wm       sync.Mutex
+	watchers []*watcher    // Copy on write
+	readyC   *readyChan    // Created by ReadyC
+	onR, onW *watcher      // Set by OnReadable and OnWritable
+	bp       *backpressure // Set by Backpressure
 	// Must hold respective lock to access
 	r fdCtl // Control fields for Read operations
 	w fdCtl // Control fields for Write operations
 }
 
 // NewFile returns a new File with the given file descriptor and name.
+// The File is served by the default Poller.
 func NewFile(fd uintptr, name string) (*File, error) {
-	err := syscall.SetNonblock(int(fd), true)
+	p, err := defaultPoller()
 	if err != nil {
 		return nil, err
 	}
-	file := &File{fd: int(fd), name: name}
-	file.r.cond = sync.NewCond(&sync.Mutex{})
-	file.w.cond = sync.NewCond(&sync.Mutex{})
-	err = register(file)
+	return p.NewFile(fd, name)
+}
+
+// Open the named path for reading, writing or both, depnding on the
+// flags argument. The File is served by the default Poller.
+func Open(name string, flags int) (*File, error) {
+	p, err := defaultPoller()
 	if err != nil {
 		return nil, err
 	}
-	return file, nil
+	return p.Open(name, flags)
 }
 
-// Open the named path for reading, writing or both, depnding on the
-// flags argument.
-func Open(name string, flags int) (*File, error) {
-	fd, err := syscall.Open(name, flags|syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0666)
+// OpenFile is like Open, with the permissions perm (before the umask)
+// for a file created with O_CREATE. The File is served by the default
+// Poller.
+func OpenFile(name string, flags int, perm os.FileMode) (*File, error) {
+	p, err := defaultPoller()
 	if err != nil {
 		return nil, err
 	}
-	return NewFile(uintptr(fd), name)
+	return p.OpenFile(name, flags, perm)
+}
+
+// OpenContext is like OpenFile, but it gives up with ctx.Err() once
+// ctx is done, for devices whose open(2) may block regardless of
+// O_NONBLOCK. The File is served by the default Poller.
+func OpenContext(ctx context.Context, name string, flags int, perm os.FileMode) (*File, error) {
+	p, err := defaultPoller()
+	if err != nil {
+		return nil, err
+	}
+	return p.OpenContext(ctx, name, flags, perm)
 }
 
 // NewFromFile returns a new *poll.File based on the given *os.File.
 // You don't need to worry about closing the *os.File, *poll.File already does it.
+// The File is served by the default Poller.
 func NewFromFile(of OsFile) (*File, error) {
-	f, err := NewFile(of.Fd(), of.Name())
+	p, err := defaultPoller()
 	if err != nil {
 		return nil, err
 	}
-	f.closeF = of.Close
-	return f, nil
+	return p.NewFromFile(of)
 }
 
 // Name returns the name of the file as presented to Open.
@@ -107,6 +173,9 @@ func (f *File) Read(p []byte) (n int, err error) {
 	f.r.m.Lock()
 	n, err = f.sysrw(false, p)
 	f.r.m.Unlock()
+	if err != nil && err != io.EOF {
+		err = f.opError("read", err)
+	}
 	return
 }
 
@@ -117,59 +186,235 @@ func (f *File) Write(p []byte) (n int, err error) {
 	f.w.m.Lock()
 	for n != len(p) {
 		var nn int
-		nn, err = f.sysrw(true, p[n:])
+		b := p[n:]
+		if f.w.chunk > 0 && len(b) > f.w.chunk {
+			b = b[:f.w.chunk]
+		}
+		nn, err = f.sysrw(true, b)
 		n += nn
 		if err != nil {
+			err = f.opError("write", err)
 			break
 		}
+		if f.w.chunk > 0 && n != len(p) {
+			// Let other writers in between chunks.
+			f.w.m.Unlock()
+			runtime.Gosched()
+			f.w.m.Lock()
+		}
 	}
 	f.w.m.Unlock()
 	return
 }
 
+// WriteOnce writes p with a single successful write(2), waiting for
+// File to become writable if needed, and returns what it accepted,
+// which may be less than len(p) without error. Unlike Write, a short
+// write isn't completed, which would be wrong on message oriented
+// descriptors (datagram sockets, some character devices), and
+// SetWriteChunk doesn't apply.
+func (f *File) WriteOnce(p []byte) (n int, err error) {
+	f.w.m.Lock()
+	n, err = f.sysrw(true, p)
+	f.w.m.Unlock()
+	if err != nil {
+		err = f.opError("write", err)
+	}
+	return n, err
+}
+
+// SetWriteChunk makes Write issue at most n bytes per system call,
+// releasing File to other writers between chunks, so a huge Write
+// doesn't hold the writing side for long and is interrupted promptly
+// by deadlines and Close. The data of concurrent Writes may then be
+// interleaved at chunk boundaries. Zero (the default) disables
+// chunking.
+func (f *File) SetWriteChunk(n int) {
+	if n < 0 {
+		n = 0
+	}
+	f.w.m.Lock()
+	f.w.chunk = n
+	f.w.m.Unlock()
+}
+
+// Seek sets the offset for the next Read or Write on File to offset,
+// interpreted according to whence: 0 means relative to the origin of
+// the file, 1 means relative to the current offset, and 2 means
+// relative to the end. It returns the new offset and an error, if any.
+// Seek waits for in-flight Reads and Writes to finish (subject to their
+// deadlines). Only seekable descriptors (regular files, block devices)
+// support it.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.r.m.Lock()
+	defer f.r.m.Unlock()
+	f.w.m.Lock()
+	defer f.w.m.Unlock()
+	if err := f.Lock(); err != nil {
+		return 0, err
+	}
+	defer f.Unlock()
+	ret, err := syscall.Seek(f.fd, offset, whence)
+	if err != nil {
+		return 0, f.opError("seek", err)
+	}
+	return ret, nil
+}
+
 func (f *File) sysrw(write bool, p []byte) (n int, err error) {
-	var fdc *fdCtl
 	var rwfun func(int, []byte) (int, error)
 	var errEOF error
 
 	if !write {
 		// Prepare things for Read.
-		fdc = &f.r
-		rwfun = syscall.Read
+		rwfun = sysRead
 		errEOF = io.EOF
 	} else {
 		// Prepare things for Write.
-		fdc = &f.w
-		rwfun = syscall.Write
+		rwfun = sysWrite
 		errEOF = io.ErrUnexpectedEOF
 	}
+	n, err = f.sysio(write, func(fd int) (int, error) {
+		return rwfun(fd, p)
+	})
+	if err == nil && n == 0 && len(p) != 0 {
+		err = errEOF
+	}
+	return n, err
+}
+
+// sysio calls fn with the file descriptor until it returns an error
+// other than EAGAIN, waiting for the descriptor to become ready (for
+// reading or writing, depending on write) between attempts. It fails
+// with ErrClosed or ErrTimeout if the File is closed or the deadline
+// for the direction expires while waiting. fn is called without
+// holding the cond lock, so deadlines and Close are processed while a
+// long copy is in progress; the descriptor is kept open by f.io.
+func (f *File) sysio(write bool, fn func(fd int) (int, error)) (n int, err error) {
+	return f.sysioWait(write, true, fn)
+}
+
+// ctl returns the control of the direction of File given by write.
+func (f *File) ctl(write bool) *fdCtl {
+	if write {
+		return &f.w
+	}
+	return &f.r
+}
+
+// sysioWait is sysio, failing with ErrWouldBlock instead of waiting
+// if wait is false. fn is then called once.
+func (f *File) sysioWait(write, wait bool, fn func(fd int) (int, error)) (n int, err error) {
+	// Assigned once, so closures capture it by value.
+	fdc := f.ctl(write)
 	// Read & Write are identical
 	fdc.cond.L.Lock()
 	defer fdc.cond.L.Unlock()
+	woken := false
+	var spinUntil, waitStart time.Time
 	for {
 		if f.closed {
 			return 0, ErrClosed
 		}
+		if f.bad {
+			return 0, ErrBadFd
+		}
 		if fdc.timeout {
 			return 0, ErrTimeout
 		}
-		n, err = rwfun(f.fd, p)
+		if fdc.shut {
+			if write {
+				return 0, errPipe
+			}
+			return 0, nil // End of file
+		}
+		if fdc.quota != nil && fdc.quota.exceeded() {
+			return 0, ErrQuotaExceeded
+		}
+		seq := fdc.seq
+		var t0 time.Time
+		if fdc.lat != nil {
+			t0 = time.Now()
+			if woken && fdc.evTime.After(waitStart) {
+				fdc.lat.Dispatch.Record(t0.Sub(fdc.evTime))
+			}
+		}
+		fdc.cond.L.Unlock()
+		f.io.RLock()
+		n, err = fn(f.fd)
+		f.io.RUnlock()
+		fdc.cond.L.Lock()
+		if fdc.lat != nil && !t0.IsZero() {
+			fdc.lat.Syscall.Record(time.Since(t0))
+		}
+		if fdc.hook != nil {
+			fdc.hook(n, err)
+		}
 		if err != nil {
 			n = 0
-			if err != syscall.EAGAIN {
+			if err != errAgain {
+				fdc.lastErr = err
 				break
 			}
 			// EAGAIN
-			startTrack(f.fd, write)
+			if err = f.pendingError(fdc); err != nil {
+				fdc.lastErr = err
+				break
+			}
+			if woken {
+				fdc.spurious++
+			}
+			if !wait {
+				err = ErrWouldBlock
+				break
+			}
+			if fdc.seq != seq {
+				// Notified during the call, retry.
+				woken = false
+				continue
+			}
+			if fdc.busy != nil && fdc.busy.on {
+				// Busy poll for a while before waiting.
+				now := time.Now()
+				if spinUntil.IsZero() {
+					spinUntil = now.Add(fdc.busy.Spin)
+				}
+				if now.Before(spinUntil) {
+					woken = false
+					fdc.cond.L.Unlock()
+					runtime.Gosched()
+					fdc.cond.L.Lock()
+					continue
+				}
+			}
+			f.p.startTrack(f.fd, write)
+			var retry *time.Timer
+			if write && f.quirks&QuirkNoWritable != 0 {
+				retry = time.AfterFunc(QuirkPoll, func() { fdc.wake(false, time.Time{}) })
+			}
+			if fdc.lat != nil {
+				waitStart = time.Now()
+			}
+			fdc.waiters++
 			fdc.cond.Wait()
-			if f.closed || fdc.timeout {
-				stopTrack(f.fd, write)
+			fdc.waiters--
+			if retry != nil {
+				retry.Stop()
 			}
+			woken = true
 			continue
 		}
-		if n == 0 && len(p) != 0 {
-			err = errEOF
-			break
+		if n > 0 {
+			// Whatever was recorded by the event loop is over.
+			fdc.events &^= Hangup | ErrorEvent
+			fdc.bytes += uint64(n)
+			fdc.last = time.Now()
+			if fdc.quota != nil {
+				fdc.quota.add(n)
+			}
+		}
+		if fdc.busy != nil {
+			fdc.busy.observe()
 		}
 		break
 	}
@@ -182,8 +427,40 @@ func (f *File) Close() error {
 		return err
 	}
 	defer f.Unlock()
+	f.shutdown()
+	if f.lockF != "" {
+		os.Remove(f.lockF)
+	}
+	if f.closeF != nil {
+		return f.closeF()
+	}
+	return syscall.Close(f.fd)
+}
+
+// Detach removes File from its Poller, without closing the
+// descriptor, and returns the descriptor, back in blocking mode, for
+// code expecting ordinary blocking semantics (e.g. os.NewFile). File
+// behaves as closed afterwards. Files created by NewFromFile still
+// share the descriptor with their *os.File, which closes it when
+// closed or garbage collected.
+func (f *File) Detach() (uintptr, error) {
+	if err := f.Lock(); err != nil {
+		return 0, err
+	}
+	defer f.Unlock()
+	f.shutdown()
+	if err := setNonblock(f.fd, false); err != nil {
+		return 0, &OpError{Op: "detach", Name: f.name, Err: err}
+	}
+	return uintptr(f.fd), nil
+}
+
+// shutdown marks File closed, removes it from its Poller and wakes up
+// everybody waiting on it. The caller must hold Lock.
+func (f *File) shutdown() {
 	f.closed = true
-	unregister(f)
+	f.p.unregister(f)
+	f.Unpublish()
 	if f.r.timer != nil {
 		f.r.timer.Stop()
 	}
@@ -193,10 +470,7 @@ func (f *File) Close() error {
 	// Wake up everybody waiting on File.
 	f.r.cond.Broadcast()
 	f.w.cond.Broadcast()
-	if f.closeF != nil {
-		return f.closeF()
-	}
-	return syscall.Close(f.fd)
+	f.notifyWatchers(0)
 }
 
 // SetDeadline sets the deadline for Read and write operations on File.
@@ -235,27 +509,56 @@ func (f *File) setDeadline(write bool, t time.Time) error {
 	}
 	fdc.deadline = t
 	fdc.timeout = false
-	if t.IsZero() {
-		if fdc.timer != nil {
-			fdc.timer.Stop()
-		}
-	} else {
+	// The timer is re-armed only if it would fire too late. Otherwise
+	// timerEvent re-arms it when it fires before the deadline, so
+	// extending (or clearing) the deadline before every operation is
+	// cheap.
+	rearm := !t.IsZero() && (fdc.fire.IsZero() || t.Before(fdc.fire.Add(-DeadlineSlack)))
+	if rearm {
 		d := t.Sub(time.Now())
 		if fdc.timer == nil {
-			fdc.timer = time.AfterFunc(d,
+			fdc.timer = f.p.newTimer(d,
 				func() { f.timerEvent(write) })
 		} else {
 			fdc.timer.Stop()
 			fdc.timer.Reset(d)
 		}
+		fdc.fire = t
 	}
 	fdc.cond.L.Unlock()
+	if rearm {
+		f.p.deadlineChanged()
+	}
 	return nil
 }
 
+// deadlineTimer is the timer of a deadline: a *time.Timer, or a timer
+// multiplexed by the event loop (see TimerFD).
+type deadlineTimer interface {
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// newTimer returns a deadlineTimer which calls fn after d.
+func (p *Poller) newTimer(d time.Duration, fn func()) deadlineTimer {
+	if t := p.loopTimer(d, fn); t != nil {
+		return t
+	}
+	return time.AfterFunc(d, fn)
+}
+
+// isClosed reports whether File has been closed.
+func (f *File) isClosed() bool {
+	f.r.cond.L.Lock()
+	closed := f.closed
+	f.r.cond.L.Unlock()
+	return closed
+}
+
 // Lock locks the file. It must be called before perfoming
 // miscellaneous operations (e.g. ioctls) on the underlying system
-// file descriptor.
+// file descriptor. It waits for in-flight Read and Write syscalls to
+// return.
 func (f *File) Lock() error {
 	f.r.cond.L.Lock()
 	f.w.cond.L.Lock()
@@ -264,15 +567,31 @@ func (f *File) Lock() error {
 		f.r.cond.L.Unlock()
 		return ErrClosed
 	}
+	f.io.Lock()
 	return nil
 }
 
 // Unlock unlocks the file.
 func (f *File) Unlock() {
+	f.io.Unlock()
 	f.w.cond.L.Unlock()
 	f.r.cond.L.Unlock()
 }
 
+// invalidate is called by the event loops when the descriptor of File
+// turns out to be invalid (e.g. it was closed using the descriptor
+// number instead of File). Blocked and further operations fail with
+// ErrBadFd.
+func (f *File) invalidate() {
+	if err := f.Lock(); err != nil {
+		return
+	}
+	f.bad = true
+	f.r.cond.Broadcast()
+	f.w.cond.Broadcast()
+	f.Unlock()
+}
+
 func (f *File) timerEvent(write bool) {
 	var fdc *fdCtl
 
@@ -284,10 +603,16 @@ func (f *File) timerEvent(write bool) {
 		fdc = &f.w
 	}
 	fdc.cond.L.Lock()
-	if !f.closed && !fdc.timeout &&
-		!fdc.deadline.IsZero() && !fdc.deadline.After(time.Now()) {
-		fdc.timeout = true
-		fdc.cond.Broadcast()
+	fdc.fire = time.Time{}
+	if !f.closed && !fdc.timeout && !fdc.deadline.IsZero() {
+		if d := fdc.deadline.Sub(time.Now()); d > 0 {
+			// Deadline extended after arming the timer.
+			fdc.timer.Reset(d)
+			fdc.fire = fdc.deadline
+		} else {
+			fdc.timeout = true
+			fdc.cond.Broadcast()
+		}
 	}
 	fdc.cond.L.Unlock()
 }
@@ -0,0 +1,122 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// newPipe returns the ends of a pipe served by the default Poller.
+func newPipe(tb testing.TB) (r, w *File) {
+	tb.Helper()
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if r, err = NewFromFile(pr); err != nil {
+		tb.Fatal(err)
+	}
+	if w, err = NewFromFile(pw); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		r.Close()
+		w.Close()
+	})
+	return r, w
+}
+
+func TestReadTimeoutNoAllocs(t *testing.T) {
+	r, _ := newPipe(t)
+	r.SetReadDeadline(time.Unix(1, 0))
+	var buf [1]byte
+	if _, err := r.Read(buf[:]); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Read error = %v, want ErrTimeout", err)
+	}
+	if n := testing.AllocsPerRun(100, func() { r.Read(buf[:]) }); n != 0 {
+		t.Fatalf("Read timeout allocates %v times", n)
+	}
+}
+
+func TestWriteClosedNoAllocs(t *testing.T) {
+	_, w := newPipe(t)
+	w.Close()
+	buf := []byte{0}
+	if _, err := w.Write(buf); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Write error = %v, want ErrClosed", err)
+	}
+	if n := testing.AllocsPerRun(100, func() { w.Write(buf) }); n != 0 {
+		t.Fatalf("Write to closed File allocates %v times", n)
+	}
+}
+
+func TestOpErrorShared(t *testing.T) {
+	r, w := newPipe(t)
+	if r.opError("read", ErrTimeout) != r.opError("read", ErrTimeout) {
+		t.Fatal("OpErrors wrapping an Error are not pre-built")
+	}
+	if r.opError("read", ErrTimeout) == r.opError("write", ErrTimeout) {
+		t.Fatal("operations share OpErrors")
+	}
+	if r.opError("read", ErrTimeout) == w.opError("read", ErrTimeout) {
+		t.Fatal("Files share OpErrors")
+	}
+	err := r.opError("unknown", ErrTimeout)
+	var oe *OpError
+	if !errors.As(err, &oe) || oe.Op != "unknown" || oe.Err != ErrTimeout {
+		t.Fatalf("opError = %#v", err)
+	}
+}
+
+func BenchmarkReadTimeout(b *testing.B) {
+	r, _ := newPipe(b)
+	r.SetReadDeadline(time.Unix(1, 0))
+	var buf [1]byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Read(buf[:])
+	}
+}
+
+func BenchmarkWriteClosed(b *testing.B) {
+	_, w := newPipe(b)
+	w.Close()
+	buf := []byte{0}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.Write(buf)
+	}
+}
+
+func TestHangupCleared(t *testing.T) {
+	r, w := newPipe(t)
+	read := func() error {
+		r.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		_, err := r.Read(make([]byte, 8))
+		return err
+	}
+	r.recordEvents(Hangup)
+	if err := read(); !errors.Is(err, ErrHangup) {
+		t.Fatalf("Read after a hang-up = %v", err)
+	}
+	if _, err := w.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := read(); err != nil {
+		t.Fatal(err)
+	}
+	if err := read(); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Read after a transfer = %v", err)
+	}
+	r.recordEvents(Hangup)
+	r.recordEvents(Readable)
+	if err := read(); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Read after readiness = %v", err)
+	}
+}
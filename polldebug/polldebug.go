@@ -0,0 +1,110 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+// Package polldebug serves the state and run statistics of poll
+// Pollers over HTTP, as JSON or HTML. Like net/http/pprof, importing it
+// registers its handler for the default Poller on the default mux:
+//
+//	import _ "github.com/jaracil/poll/polldebug"
+//
+// The page is served at /debug/poll. Add "?format=json" for JSON and
+// "&goroutines=1" to include the stacks of all goroutines.
+package polldebug
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"runtime"
+
+	"github.com/jaracil/poll"
+)
+
+func init() {
+	http.Handle("/debug/poll", Handler(nil))
+}
+
+// Report is the information served by Handler.
+type Report struct {
+	Stats poll.PollerStats
+	State poll.PollerState
+}
+
+// Handler returns an http.Handler serving the state and statistics of
+// p, or of the default Poller if p is nil.
+func Handler(p *poll.Poller) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rep Report
+		if p != nil {
+			rep.Stats, rep.State = p.Stats(), p.State()
+		} else {
+			var err error
+			if rep.Stats, err = poll.Stats(); err == nil {
+				rep.State, err = poll.State()
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if r.FormValue("goroutines") != "" {
+			rep.State.Goroutines = stacks()
+		}
+		if r.FormValue("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			enc.Encode(&rep)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := page.Execute(w, &rep); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// stacks returns the stacks of all goroutines.
+func stacks() string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+var page = template.Must(template.New("poll").Parse(`<!DOCTYPE html>
+<html>
+<head><title>poll</title></head>
+<body>
+<h1>Poller</h1>
+{{with .Stats}}
+<table>
+<tr><td>Started</td><td>{{.Started}}</td></tr>
+<tr><td>Waits</td><td>{{.Waits}}</td></tr>
+<tr><td>Events</td><td>{{.Events}} ({{printf "%.1f" .EventsPerSec}}/s, {{printf "%.2f" .AvgBatch}} per wait)</td></tr>
+<tr><td>Wakeups</td><td>{{.Wakeups}} requested, {{.WakeupReads}} handled</td></tr>
+<tr><td>Wait time</td><td>{{.WaitTime}}</td></tr>
+<tr><td>Dispatch time</td><td>{{.DispatchTime}}</td></tr>
+<tr><td>Dropped events</td><td>{{.Dropped}}</td></tr>
+<tr><td>Wait errors</td><td>{{.WaitErrors}}{{with .LastWaitError}} (last: {{.}}){{end}}</td></tr>
+</table>
+{{end}}
+<h2>Files at {{.State.Time}}</h2>
+<table border="1">
+<tr><th>Fd</th><th>Name</th><th>Dir</th><th>Deadline</th><th>Timed out</th><th>Waiters</th><th>Spurious</th><th>Busy poll</th><th>Bytes</th><th>Last event</th><th>Last error</th></tr>
+{{range .State.Files}}
+<tr><td rowspan="2">{{.Fd}}</td><td rowspan="2">{{.Name}}{{if .Closed}} (closed){{end}}</td><td>read</td>{{template "dir" .Read}}</tr>
+<tr><td>write</td>{{template "dir" .Write}}</tr>
+{{end}}
+</table>
+{{with .State.Goroutines}}<h2>Goroutines</h2><pre>{{.}}</pre>{{end}}
+</body>
+</html>
+{{define "dir"}}<td>{{if .Deadline}}{{.Deadline}}{{end}}</td><td>{{.TimedOut}}</td><td>{{.Waiters}}</td><td>{{.Spurious}}</td><td>{{.BusyPoll}}</td><td>{{.Bytes}}</td><td>{{if .LastEvent}}{{.LastEvent}}{{end}}</td><td>{{.LastErr}}</td>{{end}}
+`))
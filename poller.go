@@ -0,0 +1,294 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// Based on Nick Patavalis (npat@efault.net) poller package.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"context"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Poller is an event loop that multiplexes readiness notifications for
+// a set of Files. Every Poller runs its own goroutine and owns its
+// system resources (epoll/kqueue descriptor, wakeup pipe, etc.), so
+// independent subsystems can use isolated loops that are shut down with
+// Close.
+//
+// The package-level NewFile, Open and NewFromFile functions use a
+// default Poller which is created on first use.
+type Poller struct {
+	stats    loopStats // First for 64-bit atomic alignment.
+	started  time.Time
+	evTime   time.Time // When the events being dispatched were observed. Loop only.
+	fdm      map[int]*File
+	fdmLock  sync.Mutex
+	closed   bool          // Set by Close, never cleared. Guarded by fdmLock.
+	quit     bool          // Loop must exit. Guarded by fdmLock.
+	done     chan struct{} // Closed when the loop exits.
+	closeErr error         // Error releasing loop resources.
+	// Set by SetMaxFiles. Guarded by fdmLock.
+	maxFiles    int
+	limitPolicy LimitPolicy
+	onDropped   func(fd int, events Events) // Set by OnDropped. Guarded by fdmLock.
+	pollerImpl
+}
+
+// NewPoller creates a new Poller and starts its event loop.
+func NewPoller() (*Poller, error) {
+	return newPoller(nil)
+}
+
+// newPoller creates a new Poller whose event loop goroutine calls
+// setup, if not nil, before starting. If setup fails the loop is not
+// started.
+func newPoller(setup func() error) (*Poller, error) {
+	p := &Poller{
+		fdm:     map[int]*File{},
+		done:    make(chan struct{}),
+		started: time.Now(),
+	}
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+	if setup == nil {
+		go p.evLoop()
+		return p, nil
+	}
+	errc := make(chan error)
+	go func() {
+		err := setup()
+		errc <- err
+		if err == nil {
+			p.evLoop()
+		}
+	}()
+	if err := <-errc; err != nil {
+		p.release()
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewFile returns a new File, served by Poller, with the given file
+// descriptor and name.
+func (p *Poller) NewFile(fd uintptr, name string) (*File, error) {
+	err := setNonblock(int(fd), true)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{fd: int(fd), name: name, p: p}
+	file.r.cond = sync.NewCond(&sync.Mutex{})
+	file.w.cond = sync.NewCond(&sync.Mutex{})
+	file.r.last = time.Now()
+	file.w.last = file.r.last
+	err = p.register(file)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Open the named path for reading, writing or both, depending on the
+// flags argument. The returned File is served by Poller. On Android
+// O_NOCTTY is added to flags for ttys (see DeviceClass).
+func (p *Poller) Open(name string, flags int) (*File, error) {
+	return p.OpenFile(name, flags, 0666)
+}
+
+// OpenFile is like Open, with the permissions perm (before the umask)
+// for a file created with O_CREATE.
+func (p *Poller) OpenFile(name string, flags int, perm os.FileMode) (*File, error) {
+	fd, err := sysOpen(name, flags|openFlags(name), perm)
+	if err != nil {
+		return nil, &OpError{Op: "open", Name: name, Err: err}
+	}
+	f, err := p.NewFile(uintptr(fd), name)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, &OpError{Op: "open", Name: name, Err: err}
+	}
+	return f, nil
+}
+
+// OpenContext is like OpenFile, but it gives up with ctx.Err() once
+// ctx is done. Some devices (tape drives, some USB gadgets, etc.) block
+// in open(2) in spite of O_NONBLOCK; the open is done by a helper
+// goroutine, which closes the descriptor if it comes too late.
+func (p *Poller) OpenContext(ctx context.Context, name string, flags int, perm os.FileMode) (*File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type result struct {
+		f   *File
+		err error
+	}
+	c := make(chan result)
+	abandoned := make(chan struct{})
+	go func() {
+		f, err := p.OpenFile(name, flags, perm)
+		select {
+		case c <- result{f, err}:
+		case <-abandoned:
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	select {
+	case r := <-c:
+		return r.f, r.err
+	case <-ctx.Done():
+		close(abandoned)
+		return nil, ctx.Err()
+	}
+}
+
+// NewFromFile returns a new *poll.File, served by Poller, based on the
+// given *os.File. See the NewFromFile function.
+func (p *Poller) NewFromFile(of OsFile) (*File, error) {
+	f, err := p.NewFile(of.Fd(), of.Name())
+	if err != nil {
+		return nil, err
+	}
+	f.closeF = of.Close
+	return f, nil
+}
+
+// Close closes every File served by Poller, waking up any blocked
+// operation, stops the event loop and releases its resources. Files
+// can't be added to a closed Poller.
+func (p *Poller) Close() error {
+	p.fdmLock.Lock()
+	if p.closed {
+		p.fdmLock.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	p.fdmLock.Unlock()
+	for _, f := range p.Files() {
+		f.Close()
+	}
+	p.fdmLock.Lock()
+	p.quit = true
+	p.fdmLock.Unlock()
+	p.wakeup()
+	<-p.done
+	return p.closeErr
+}
+
+// quitting reports whether the event loop must exit.
+func (p *Poller) quitting() bool {
+	p.fdmLock.Lock()
+	q := p.quit
+	p.fdmLock.Unlock()
+	return q
+}
+
+// Files returns the Files currently served by Poller.
+func (p *Poller) Files() []*File {
+	p.fdmLock.Lock()
+	defer p.fdmLock.Unlock()
+	files := make([]*File, 0, len(p.fdm))
+	for _, f := range p.fdm {
+		files = append(files, f)
+	}
+	return files
+}
+
+func (p *Poller) register(f *File) error {
+	if err := p.checkLimit(f.name, f.fd); err != nil {
+		return err
+	}
+	if err := runRegisterHooks(f.name, f.fd); err != nil {
+		return err
+	}
+	err := p.insert(f)
+	if err != nil {
+		runUnregisterHooks(f.name, f.fd)
+	}
+	return err
+}
+
+// insert adds f to the Files served by Poller.
+func (p *Poller) insert(f *File) error {
+	p.fdmLock.Lock()
+	defer p.fdmLock.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	p.fdm[f.fd] = f
+	if err := p.add(f.fd); err != nil {
+		delete(p.fdm, f.fd)
+		return err
+	}
+	return nil
+}
+
+func (p *Poller) unregister(f *File) error {
+	p.fdmLock.Lock()
+	if p.fdm[f.fd] != f {
+		p.fdmLock.Unlock()
+		return nil
+	}
+	delete(p.fdm, f.fd)
+	err := p.del(f.fd)
+	p.fdmLock.Unlock()
+	runUnregisterHooks(f.name, f.fd)
+	return err
+}
+
+func (p *Poller) getFile(fd int) *File {
+	p.fdmLock.Lock()
+	f := p.fdm[fd]
+	p.fdmLock.Unlock()
+	return f
+}
+
+var defPoller *Poller
+var defPollerLock sync.Mutex
+
+// defaultPoller returns the package default Poller, creating it if
+// needed.
+func defaultPoller() (*Poller, error) {
+	defPollerLock.Lock()
+	defer defPollerLock.Unlock()
+	if defPoller == nil {
+		p, err := NewPoller()
+		if err != nil {
+			return nil, err
+		}
+		defPoller = p
+	}
+	return defPoller, nil
+}
+
+// Shutdown closes every File served by the default Poller (waking up
+// any blocked operation), terminates its event loop and releases its
+// descriptors. A new default Poller is started by Reinit or on the next
+// call to NewFile, Open or NewFromFile.
+func Shutdown() error {
+	defPollerLock.Lock()
+	p := defPoller
+	defPoller = nil
+	defPollerLock.Unlock()
+	if p == nil {
+		return nil
+	}
+	return p.Close()
+}
+
+// Reinit shuts down the default Poller, if running, and starts a new
+// one.
+func Reinit() error {
+	if err := Shutdown(); err != nil {
+		return err
+	}
+	_, err := defaultPoller()
+	return err
+}
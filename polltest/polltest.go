@@ -0,0 +1,27 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+// Package polltest provides fakes of the interfaces of package poll
+// (ReadWriteCloserDeadliner, etc.), for testing code written against
+// them without devices.
+package polltest
+
+import (
+	"net"
+
+	"github.com/jaracil/poll"
+)
+
+// Pipe returns the two ends of a synchronous, in-memory, full duplex
+// connection: what is written to one end is read from the other. Like
+// a File, each end supports read and write deadlines, and Close
+// unblocks the operations in progress. Expired deadlines fail with
+// os.ErrDeadlineExceeded and closed ends with net.ErrClosed, which
+// errors.Is also matches for the ErrTimeout and ErrClosed of Files.
+func Pipe() (a, b poll.ReadWriteCloserDeadliner) {
+	return net.Pipe()
+}
+
+var _ poll.ReadWriteCloserDeadliner = net.Conn(nil)
@@ -0,0 +1,30 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// DefaultLockDir is the directory used by LockFile when none is given.
+// Android has no /var/lock, and apps confined by SELinux can't write
+// here either; they must pass a directory of their own (e.g. the app
+// cache directory).
+const DefaultLockDir = "/data/local/tmp"
+
+// openFlags returns the flags added to those given to Open for the
+// named device.
+func openFlags(name string) int {
+	return classFlags(DeviceClassOf(name))
+}
+
+// classFlags returns the flags added to those given to Open for the
+// devices of class c. Device nodes opened by Android services must not
+// become their controlling terminal.
+func classFlags(c DeviceClass) int {
+	if c == ClassTTY {
+		return syscall.O_NOCTTY
+	}
+	return 0
+}
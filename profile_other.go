@@ -0,0 +1,24 @@
+//go:build !android
+// +build !android
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+// DefaultLockDir is the directory used by LockFile when none is given.
+const DefaultLockDir = "/var/lock"
+
+// openFlags returns the flags added to those given to Open for the
+// named device.
+func openFlags(name string) int {
+	return 0
+}
+
+// classFlags returns the flags added to those given to Open for the
+// devices of class c.
+func classFlags(c DeviceClass) int {
+	return 0
+}
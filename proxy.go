@@ -0,0 +1,295 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProxyStats are the metrics collected by a Proxy.
+type ProxyStats struct {
+	Accepted uint64 // Connections accepted
+	Rejected uint64 // Connections rejected (limit reached or Dial failure)
+	Active   uint64 // Connections currently relayed
+	BytesIn  uint64 // Bytes relayed from clients to backends
+	BytesOut uint64 // Bytes relayed from backends to clients
+}
+
+// Diff returns the counters accumulated between the snapshots prev and
+// s. Active, a gauge, is taken from s.
+func (s ProxyStats) Diff(prev ProxyStats) ProxyStats {
+	return ProxyStats{
+		Accepted: s.Accepted - prev.Accepted,
+		Rejected: s.Rejected - prev.Rejected,
+		Active:   s.Active,
+		BytesIn:  s.BytesIn - prev.BytesIn,
+		BytesOut: s.BytesOut - prev.BytesOut,
+	}
+}
+
+// Proxy accepts connections on a net.Listener and relays each of them
+// to a backend obtained from Dial. Data is spliced between them (see
+// Splice) when both ends are Files supporting it, e.g. a Conn from a
+// Listener and a socket or pipe backend; other endpoints, including
+// ttys, are relayed through a user space buffer. Together with FileBackend it makes
+// a serial-to-TCP gateway:
+//
+//	p := &poll.Proxy{Dial: poll.FileBackend(tty), MaxConns: 1}
+//	err := p.Serve(l)
+type Proxy struct {
+	// Dial returns the backend for a new connection. The backend is
+	// closed when the connection ends.
+	Dial func() (io.ReadWriteCloser, error)
+	// MaxConns limits the number of simultaneous connections. Extra
+	// connections are closed right after being accepted. Zero means no
+	// limit.
+	MaxConns int
+	// IdleTimeout closes connections with no traffic in either
+	// direction for longer than IdleTimeout. Zero means no timeout.
+	IdleTimeout time.Duration
+
+	stats  ProxyStats // Atomic access
+	mu     sync.Mutex
+	ls     map[net.Listener]struct{}
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Stats returns the metrics of Proxy.
+func (p *Proxy) Stats() ProxyStats {
+	return ProxyStats{
+		Accepted: atomic.LoadUint64(&p.stats.Accepted),
+		Rejected: atomic.LoadUint64(&p.stats.Rejected),
+		Active:   atomic.LoadUint64(&p.stats.Active),
+		BytesIn:  atomic.LoadUint64(&p.stats.BytesIn),
+		BytesOut: atomic.LoadUint64(&p.stats.BytesOut),
+	}
+}
+
+// Serve accepts connections on l and relays them until l fails or
+// Proxy is closed. Serve always closes l. It returns nil after Close.
+func (p *Proxy) Serve(l net.Listener) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		l.Close()
+		return ErrClosed
+	}
+	if p.ls == nil {
+		p.ls = map[net.Listener]struct{}{}
+	}
+	p.ls[l] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.ls, l)
+		p.mu.Unlock()
+		l.Close()
+	}()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			p.mu.Lock()
+			closed := p.closed
+			p.mu.Unlock()
+			if closed {
+				return nil
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		atomic.AddUint64(&p.stats.Accepted, 1)
+		if !p.track(c) {
+			atomic.AddUint64(&p.stats.Rejected, 1)
+			c.Close()
+			continue
+		}
+		p.wg.Add(1)
+		go p.serveConn(c)
+	}
+}
+
+// track adds c to the set of active connections, unless the limit has
+// been reached or Proxy is closed.
+func (p *Proxy) track(c net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || (p.MaxConns > 0 && len(p.conns) >= p.MaxConns) {
+		return false
+	}
+	if p.conns == nil {
+		p.conns = map[net.Conn]struct{}{}
+	}
+	p.conns[c] = struct{}{}
+	atomic.AddUint64(&p.stats.Active, 1)
+	return true
+}
+
+func (p *Proxy) untrack(c net.Conn) {
+	p.mu.Lock()
+	delete(p.conns, c)
+	p.mu.Unlock()
+	atomic.AddUint64(&p.stats.Active, ^uint64(0))
+}
+
+func (p *Proxy) serveConn(c net.Conn) {
+	defer p.wg.Done()
+	defer p.untrack(c)
+	defer c.Close()
+	b, err := p.Dial()
+	if err != nil {
+		atomic.AddUint64(&p.stats.Rejected, 1)
+		return
+	}
+	var last int64 = time.Now().UnixNano() // Last activity, atomic access
+	var stopped int32                      // Atomic access
+	done := make(chan struct{}, 2)
+	relay := func(dst io.Writer, src io.Reader, counter *uint64) {
+		move, stop := relayFunc(dst, src)
+		defer stop()
+		for atomic.LoadInt32(&stopped) == 0 {
+			if p.IdleTimeout > 0 {
+				SetReadDeadline(src, time.Now().Add(p.IdleTimeout))
+				if atomic.LoadInt32(&stopped) != 0 {
+					// Stopped meanwhile, the deadline set to
+					// unblock us may have been overwritten.
+					break
+				}
+			}
+			n, err := move()
+			if n > 0 {
+				atomic.StoreInt64(&last, time.Now().UnixNano())
+				atomic.AddUint64(counter, uint64(n))
+			}
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() &&
+					time.Since(time.Unix(0, atomic.LoadInt64(&last))) < p.IdleTimeout {
+					// The other direction is active.
+					continue
+				}
+				break
+			}
+		}
+		done <- struct{}{}
+	}
+	go relay(b, c, &p.stats.BytesIn)
+	go relay(c, b, &p.stats.BytesOut)
+	<-done
+	// Unblock the other direction.
+	atomic.StoreInt32(&stopped, 1)
+	c.Close()
+	b.Close()
+	<-done
+	if r, ok := b.(interface{ release() }); ok {
+		r.release()
+	}
+}
+
+// relayFunc returns a function moving the data src has ready to dst,
+// returning the number of bytes moved, or io.EOF at end of file. When
+// both are Files (or Conns, or FileBackends) supporting splice(2)
+// (sockets and pipes, on Linux) the data isn't copied through user
+// space (see Splice). Otherwise, e.g. for ttys or endpoints which
+// aren't Files, it falls back to reading into a buffer and writing it.
+// stop releases the resources of the function.
+func relayFunc(dst io.Writer, src io.Reader) (move func() (int, error), stop func()) {
+	if df, sf := proxyFile(dst), proxyFile(src); df != nil && sf != nil {
+		if relay, stop, ok := spliceRelay(df, sf); ok {
+			return func() (int, error) {
+				n, err := relay()
+				if n == 0 && err == nil {
+					err = io.EOF
+				}
+				return n, err
+			}, stop
+		}
+	}
+	buf := make([]byte, 32*1024)
+	return func() (int, error) {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return n, werr
+			}
+		}
+		return n, err
+	}, func() {}
+}
+
+// proxyFile returns the File behind a Proxy endpoint, nil if it isn't
+// one.
+func proxyFile(x interface{}) *File {
+	switch x := x.(type) {
+	case *File:
+		return x
+	case *Conn:
+		return x.File
+	case *fileBackend:
+		return x.File
+	}
+	return nil
+}
+
+// Close stops every Serve call and closes all relayed connections,
+// waiting for them to finish.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	for l := range p.ls {
+		l.Close()
+	}
+	for c := range p.conns {
+		c.Close()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	return nil
+}
+
+// FileBackend returns a Proxy Dial function which hands out f to one
+// connection at a time (further Dials fail with ErrBusy). Closing the
+// returned backend doesn't close f: it aborts the pending operations by
+// expiring its deadlines, which are cleared on the next Dial. The
+// backend is only meant to be used by Proxy.
+func FileBackend(f *File) func() (io.ReadWriteCloser, error) {
+	var busy int32
+	return func() (io.ReadWriteCloser, error) {
+		if !atomic.CompareAndSwapInt32(&busy, 0, 1) {
+			return nil, ErrBusy
+		}
+		if err := f.SetDeadline(time.Time{}); err != nil {
+			atomic.StoreInt32(&busy, 0)
+			return nil, err
+		}
+		return &fileBackend{File: f, busy: &busy}, nil
+	}
+}
+
+type fileBackend struct {
+	*File
+	busy *int32
+}
+
+func (b *fileBackend) Close() error {
+	return b.File.SetDeadline(time.Unix(1, 0))
+}
+
+// release is called by Proxy once no goroutine uses the backend.
+func (b *fileBackend) release() {
+	atomic.StoreInt32(b.busy, 0)
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// OpenPty allocates a pseudo-terminal, returning its master side as a
+// File served by the default Poller, and the name of its slave side
+// (e.g. "/dev/pts/3"), which can be opened with Open (add O_NOCTTY
+// unless it must become the controlling terminal) or handed to a child
+// process.
+func OpenPty() (master *File, slave string, err error) {
+	fd, err := syscall.Open("/dev/ptmx", syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, "", &OpError{Op: "open", Name: "/dev/ptmx", Err: err}
+	}
+	// grantpt(3) is a no-op with devpts. unlockpt(3):
+	var unlock int32
+	if err = ioctl(fd, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err == nil {
+		// ptsname(3):
+		var n uint32
+		if err = ioctl(fd, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err == nil {
+			slave = "/dev/pts/" + strconv.Itoa(int(n))
+		}
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return nil, "", err
+	}
+	master, err = NewFile(uintptr(fd), "/dev/ptmx")
+	if err != nil {
+		syscall.Close(fd)
+		return nil, "", err
+	}
+	return master, slave, nil
+}
@@ -0,0 +1,102 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || plan9
+// +build linux darwin dragonfly freebsd netbsd openbsd plan9
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"sync"
+	"time"
+)
+
+// Quirks is a set of workarounds for misbehaving device drivers.
+type Quirks int
+
+// Driver quirks.
+const (
+	// QuirkNoWritable is for drivers that never signal the device
+	// writable again once their buffer fills up. Blocked writes are
+	// retried every QuirkPoll instead of waiting for the event.
+	QuirkNoWritable Quirks = 1 << iota
+	// QuirkSpuriousHangup is for drivers that signal a hang-up while
+	// the device is still usable (e.g. on carrier loss, or when an
+	// IrDA peer goes out of range). Hang-ups are ignored, and reads
+	// carry on until they return the end of file or an error.
+	QuirkSpuriousHangup
+)
+
+// QuirkPoll is how often blocked writes are retried on Files with
+// QuirkNoWritable.
+var QuirkPoll = 10 * time.Millisecond
+
+var quirksLock sync.Mutex
+
+// driverQuirks are the quirks of the known misbehaving drivers, by
+// driver name (see Driver).
+var driverQuirks = map[string]Quirks{
+	// IrCOMM hangs up the tty whenever the IrDA peer goes out of
+	// range, and resumes once it's back.
+	"ircomm": QuirkSpuriousHangup,
+	// cdc_acm hangs up on every carrier loss reported by the modem,
+	// without CLOCAL, while the port remains usable.
+	"cdc_acm": QuirkSpuriousHangup,
+	// The legacy RocketPort driver doesn't wake up writers when its
+	// transmit buffer drains, so no writable event ever comes.
+	"rocket": QuirkNoWritable,
+}
+
+// RegisterQuirks sets the quirks of the named driver (see Driver),
+// which OpenSerial applies to the ports it opens, replacing the ones
+// built in. Zero quirks remove the driver from the registry.
+func RegisterQuirks(driver string, q Quirks) {
+	quirksLock.Lock()
+	defer quirksLock.Unlock()
+	if q == 0 {
+		delete(driverQuirks, driver)
+		return
+	}
+	driverQuirks[driver] = q
+}
+
+// DriverQuirks returns the quirks registered for the named driver.
+func DriverQuirks(driver string) Quirks {
+	quirksLock.Lock()
+	defer quirksLock.Unlock()
+	return driverQuirks[driver]
+}
+
+// SetQuirks sets the quirks of File, replacing the ones set by
+// OpenSerial.
+func (f *File) SetQuirks(q Quirks) error {
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	f.quirks = q
+	return nil
+}
+
+// Quirks returns the quirks of File.
+func (f *File) Quirks() Quirks {
+	if err := f.Lock(); err != nil {
+		return 0
+	}
+	defer f.Unlock()
+	return f.quirks
+}
+
+// applyDriverQuirks sets the quirks registered for the driver of
+// File, if known.
+func (f *File) applyDriverQuirks() {
+	driver, err := f.Driver()
+	if err != nil {
+		return
+	}
+	if q := DriverQuirks(driver); q != 0 {
+		f.SetQuirks(q)
+	}
+}
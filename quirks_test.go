@@ -0,0 +1,26 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "testing"
+
+func TestDriverQuirks(t *testing.T) {
+	if q := DriverQuirks("ircomm"); q != QuirkSpuriousHangup {
+		t.Fatalf("ircomm quirks = %d", q)
+	}
+	RegisterQuirks("ircomm", QuirkNoWritable)
+	if q := DriverQuirks("ircomm"); q != QuirkNoWritable {
+		t.Fatalf("registered quirks = %d", q)
+	}
+	RegisterQuirks("ircomm", 0)
+	if q := DriverQuirks("ircomm"); q != 0 {
+		t.Fatalf("removed quirks = %d", q)
+	}
+	RegisterQuirks("ircomm", QuirkSpuriousHangup)
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "time"
+
+// Quota limits the bytes transferred in one direction of a File. Zero
+// fields mean no limit.
+type Quota struct {
+	Total     uint64 // Bytes since the quota was set
+	PerSecond uint64 // Bytes per one second window
+}
+
+// quota keeps the usage of a Quota.
+type quota struct {
+	Quota
+	used    uint64    // Bytes since set
+	win     time.Time // Start of the current window
+	winUsed uint64    // Bytes in the current window
+}
+
+// exceeded reports whether the quota is used up.
+func (q *quota) exceeded() bool {
+	if q.Total > 0 && q.used >= q.Total {
+		return true
+	}
+	if q.PerSecond > 0 {
+		if now := time.Now(); now.Sub(q.win) >= time.Second {
+			q.win = now
+			q.winUsed = 0
+		}
+		return q.winUsed >= q.PerSecond
+	}
+	return false
+}
+
+// add records n bytes transferred.
+func (q *quota) add(n int) {
+	q.used += uint64(n)
+	q.winUsed += uint64(n)
+}
+
+// SetReadQuota limits the bytes read from File, by Read and every other
+// reading method (ReadAt, RecvMsg, Splice, etc.). Once the quota is
+// used up they fail with ErrQuotaExceeded, until the next one second
+// window for PerSecond quotas. The quota is checked before every
+// operation, so the last one may overrun it. A zero Quota removes the
+// limit.
+func (f *File) SetReadQuota(q Quota) {
+	f.r.setQuota(q)
+}
+
+// SetWriteQuota limits the bytes written to File. See SetReadQuota.
+func (f *File) SetWriteQuota(q Quota) {
+	f.w.setQuota(q)
+}
+
+func (fdc *fdCtl) setQuota(q Quota) {
+	fdc.cond.L.Lock()
+	defer fdc.cond.L.Unlock()
+	if q == (Quota{}) {
+		fdc.quota = nil
+		return
+	}
+	fdc.quota = &quota{Quota: q, win: time.Now()}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// dialRaw creates a socket and connects it to the raw address sa, for
+// address families syscall.Sockaddr doesn't cover (Bluetooth, VSOCK,
+// etc.). See DialSockaddr.
+func dialRaw(domain, typ, proto int, name string, sa unsafe.Pointer, salen uintptr, deadline time.Time) (*File, error) {
+	fd, err := sysSocket(domain, typ, proto)
+	if err != nil {
+		return nil, err
+	}
+	f, err := NewFile(uintptr(fd), name)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	if !deadline.IsZero() {
+		f.SetWriteDeadline(deadline)
+	}
+	err = f.connect(func(fd int) error {
+		return rawConnect(fd, sa, salen)
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !deadline.IsZero() {
+		f.SetWriteDeadline(time.Time{})
+	}
+	return f, nil
+}
+
+// acceptRaw is like Accept, for address families syscall.Sockaddr
+// doesn't cover: the peer address is stored in the raw buffer sa of
+// salen bytes. It returns the descriptor of the connection.
+func (f *File) acceptRaw(sa unsafe.Pointer, salen uintptr) (int, error) {
+	var nfd int
+	f.r.m.Lock()
+	_, err := f.sysio(false, func(fd int) (int, error) {
+		for {
+			l := uint32(salen)
+			var err error
+			nfd, err = rawAccept(fd, sa, &l, syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC)
+			if err != syscall.ECONNABORTED && err != syscall.EINTR {
+				return 0, err
+			}
+		}
+	})
+	f.r.m.Unlock()
+	if err != nil {
+		return -1, f.opError("accept", err)
+	}
+	return nfd, nil
+}
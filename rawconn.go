@@ -0,0 +1,62 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// rawConn implements syscall.RawConn for a File.
+type rawConn struct {
+	f *File
+}
+
+// SyscallConn returns a syscall.RawConn giving raw access to the file
+// descriptor of File, so options can be set with setsockopt, ioctl,
+// etc. without racing Close. It implements the syscall.Conn interface.
+func (f *File) SyscallConn() (syscall.RawConn, error) {
+	if f.isClosed() {
+		return nil, ErrClosed
+	}
+	return rawConn{f}, nil
+}
+
+// Control calls fn with the file descriptor. The File is locked (see
+// Lock) during the call, so the descriptor stays valid.
+func (c rawConn) Control(fn func(fd uintptr)) error {
+	if err := c.f.Lock(); err != nil {
+		return err
+	}
+	defer c.f.Unlock()
+	fn(uintptr(c.f.fd))
+	return nil
+}
+
+// Read calls fn with the file descriptor until it returns true, waiting
+// for the descriptor to become readable between calls. The read
+// deadline applies.
+func (c rawConn) Read(fn func(fd uintptr) (done bool)) error {
+	c.f.r.m.Lock()
+	defer c.f.r.m.Unlock()
+	return c.rawio(false, fn)
+}
+
+// Write calls fn with the file descriptor until it returns true,
+// waiting for the descriptor to become writable between calls. The
+// write deadline applies.
+func (c rawConn) Write(fn func(fd uintptr) (done bool)) error {
+	c.f.w.m.Lock()
+	defer c.f.w.m.Unlock()
+	return c.rawio(true, fn)
+}
+
+func (c rawConn) rawio(write bool, fn func(fd uintptr) bool) error {
+	_, err := c.f.sysio(write, func(fd int) (int, error) {
+		if !fn(uintptr(fd)) {
+			return 0, errAgain
+		}
+		return 0, nil
+	})
+	return err
+}
@@ -0,0 +1,53 @@
+//go:build linux && !386 && !s390x
+// +build linux,!386,!s390x
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// rawConnect calls connect(2) with a raw socket address, for address
+// families syscall.Sockaddr doesn't cover.
+func rawConnect(fd int, sa unsafe.Pointer, salen uintptr) error {
+	_, _, e := syscall.Syscall(syscall.SYS_CONNECT, uintptr(fd), uintptr(sa), salen)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// rawBind calls bind(2) with a raw socket address.
+func rawBind(fd int, sa unsafe.Pointer, salen uintptr) error {
+	_, _, e := syscall.Syscall(syscall.SYS_BIND, uintptr(fd), uintptr(sa), salen)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// rawAccept calls accept4(2), storing the peer address in the raw
+// buffer sa of *salen bytes.
+func rawAccept(fd int, sa unsafe.Pointer, salen *uint32, flags int) (int, error) {
+	nfd, _, e := syscall.Syscall6(syscall.SYS_ACCEPT4, uintptr(fd), uintptr(sa), uintptr(unsafe.Pointer(salen)), uintptr(flags), 0, 0)
+	if e != 0 {
+		return -1, e
+	}
+	return int(nfd), nil
+}
+
+// rawGetsockname calls getsockname(2), storing the address in the raw
+// buffer sa of *salen bytes.
+func rawGetsockname(fd int, sa unsafe.Pointer, salen *uint32) error {
+	_, _, e := syscall.RawSyscall(syscall.SYS_GETSOCKNAME, uintptr(fd), uintptr(sa), uintptr(unsafe.Pointer(salen)))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
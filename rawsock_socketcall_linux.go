@@ -0,0 +1,67 @@
+//go:build linux && (386 || s390x)
+// +build linux
+// +build 386 s390x
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// socketcall(2) call numbers.
+const (
+	socketcallBind        = 2
+	socketcallConnect     = 3
+	socketcallGetsockname = 6
+	socketcallAccept4     = 18
+)
+
+// rawConnect calls connect(2), through socketcall(2), with a raw socket
+// address, for address families syscall.Sockaddr doesn't cover.
+func rawConnect(fd int, sa unsafe.Pointer, salen uintptr) error {
+	args := [3]uintptr{uintptr(fd), uintptr(sa), salen}
+	_, _, e := syscall.Syscall(syscall.SYS_SOCKETCALL, socketcallConnect, uintptr(unsafe.Pointer(&args[0])), 0)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// rawBind calls bind(2), through socketcall(2), with a raw socket
+// address.
+func rawBind(fd int, sa unsafe.Pointer, salen uintptr) error {
+	args := [3]uintptr{uintptr(fd), uintptr(sa), salen}
+	_, _, e := syscall.Syscall(syscall.SYS_SOCKETCALL, socketcallBind, uintptr(unsafe.Pointer(&args[0])), 0)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// rawAccept calls accept4(2), through socketcall(2), storing the peer
+// address in the raw buffer sa of *salen bytes.
+func rawAccept(fd int, sa unsafe.Pointer, salen *uint32, flags int) (int, error) {
+	args := [4]uintptr{uintptr(fd), uintptr(sa), uintptr(unsafe.Pointer(salen)), uintptr(flags)}
+	nfd, _, e := syscall.Syscall(syscall.SYS_SOCKETCALL, socketcallAccept4, uintptr(unsafe.Pointer(&args[0])), 0)
+	if e != 0 {
+		return -1, e
+	}
+	return int(nfd), nil
+}
+
+// rawGetsockname calls getsockname(2), through socketcall(2), storing
+// the address in the raw buffer sa of *salen bytes.
+func rawGetsockname(fd int, sa unsafe.Pointer, salen *uint32) error {
+	args := [3]uintptr{uintptr(fd), uintptr(sa), uintptr(unsafe.Pointer(salen))}
+	_, _, e := syscall.RawSyscall(syscall.SYS_SOCKETCALL, socketcallGetsockname, uintptr(unsafe.Pointer(&args[0])), 0)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
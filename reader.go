@@ -0,0 +1,122 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// DefaultReaderSize is the buffer size of a Reader created by
+// NewReader with size <= 0.
+const DefaultReaderSize = 4096
+
+// Reader is a buffered reader for a File, like bufio.Reader, with
+// well-defined behavior on deadlines: when a Read fails (e.g. with
+// ErrTimeout) the data read so far is returned along with the error
+// and is consumed, and the next call resumes reading from File, so
+// operations can be retried once the deadline is extended.
+type Reader struct {
+	f    *File
+	buf  []byte
+	r, w int // Unread data is buf[r:w]
+}
+
+// NewReader returns a Reader for f with a buffer of size bytes
+// (DefaultReaderSize if size <= 0).
+func NewReader(f *File, size int) *Reader {
+	if size <= 0 {
+		size = DefaultReaderSize
+	}
+	return &Reader{f: f, buf: make([]byte, size)}
+}
+
+// Buffered returns the number of bytes that can be read from the
+// buffer without reading from File.
+func (b *Reader) Buffered() int {
+	return b.w - b.r
+}
+
+// fill reads once from File into the buffer.
+func (b *Reader) fill() error {
+	if b.r > 0 {
+		copy(b.buf, b.buf[b.r:b.w])
+		b.w -= b.r
+		b.r = 0
+	}
+	n, err := b.f.Read(b.buf[b.w:])
+	b.w += n
+	return err
+}
+
+// Read reads up to len(p) bytes into p, reading from File at most
+// once, and only if the buffer is empty.
+func (b *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if b.r == b.w {
+		if len(p) >= len(b.buf) {
+			// Large read, skip the buffer.
+			return b.f.Read(p)
+		}
+		if err := b.fill(); b.r == b.w {
+			return 0, err
+		}
+	}
+	n := copy(p, b.buf[b.r:b.w])
+	b.r += n
+	return n, nil
+}
+
+// ReadByte reads and returns a single byte.
+func (b *Reader) ReadByte() (byte, error) {
+	for b.r == b.w {
+		if err := b.fill(); err != nil && b.r == b.w {
+			return 0, err
+		}
+	}
+	c := b.buf[b.r]
+	b.r++
+	return c, nil
+}
+
+// ReadBytes reads until the first occurrence of delim, returning the
+// data up to and including delim. If an error (io.EOF, ErrTimeout,
+// etc.) happens before finding delim, it returns the data read so far,
+// which is consumed, and the error.
+func (b *Reader) ReadBytes(delim byte) ([]byte, error) {
+	var line []byte
+	for {
+		if i := bytes.IndexByte(b.buf[b.r:b.w], delim); i >= 0 {
+			line = append(line, b.buf[b.r:b.r+i+1]...)
+			b.r += i + 1
+			return line, nil
+		}
+		line = append(line, b.buf[b.r:b.w]...)
+		b.r, b.w = 0, 0
+		if err := b.fill(); err != nil {
+			line = append(line, b.buf[b.r:b.w]...)
+			b.r, b.w = 0, 0
+			return line, err
+		}
+	}
+}
+
+// ReadString is like ReadBytes, returning a string.
+func (b *Reader) ReadString(delim byte) (string, error) {
+	line, err := b.ReadBytes(delim)
+	return string(line), err
+}
+
+// SetReadDeadline sets the read deadline of the File of Reader. Data
+// already buffered is still returned after it expires.
+func (b *Reader) SetReadDeadline(t time.Time) error {
+	return b.f.SetReadDeadline(t)
+}
+
+var _ io.ByteReader = (*Reader)(nil)
@@ -0,0 +1,29 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "io"
+
+// writerOnly hides the ReadFrom method of a File, so io.Copy doesn't
+// call it back.
+type writerOnly struct {
+	io.Writer
+}
+
+// ReadFrom implements io.ReaderFrom, so io.Copy to a File uses it. On
+// Linux the data is moved without copying it through user space when
+// r is a regular file and File a socket (using sendfile(2)), or when
+// both are pipes or sockets (using Splice). Otherwise it's copied as
+// io.Copy does.
+func (f *File) ReadFrom(r io.Reader) (int64, error) {
+	if n, handled, err := f.sendfileFrom(r); handled {
+		return n, err
+	}
+	if n, handled, err := f.spliceFrom(r); handled {
+		return n, err
+	}
+	return io.Copy(writerOnly{f}, r)
+}
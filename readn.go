@@ -0,0 +1,55 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "io"
+
+// ReadN reads exactly n bytes from File into a newly allocated slice.
+// On error it returns the bytes read so far along with the error,
+// which is io.ErrUnexpectedEOF if the end of file is reached after
+// reading some bytes, or io.EOF if no bytes were read. The read
+// deadline applies to the whole operation.
+func (f *File) ReadN(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	got, err := io.ReadFull(f, buf)
+	return buf[:got], err
+}
+
+// ReadAll reads from File until the end of file or until max bytes
+// have been read, whichever happens first. Reaching the end of file is
+// not an error. On error it returns the bytes read so far along with
+// the error. The read deadline applies to the whole operation.
+func (f *File) ReadAll(max int) ([]byte, error) {
+	if max < 0 {
+		max = 0
+	}
+	buf := make([]byte, 0, minInt(max, 512))
+	for len(buf) < max {
+		if len(buf) == cap(buf) {
+			buf = append(buf, 0)[:len(buf)]
+		}
+		room := buf[len(buf):cap(buf)]
+		if len(room) > max-len(buf) {
+			room = room[:max-len(buf)]
+		}
+		n, err := f.Read(room)
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			return buf, nil
+		}
+		if err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,86 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "sync"
+
+// readyChan delivers the events of a File to the channel returned by
+// ReadyC, merging them while the receiver is busy.
+type readyChan struct {
+	m      sync.Mutex
+	c      chan Events
+	closed bool
+}
+
+func (rc *readyChan) deliver(f *File, ev Events) {
+	rc.m.Lock()
+	defer rc.m.Unlock()
+	if rc.closed {
+		return
+	}
+	if ev == 0 {
+		// File closed.
+		rc.closed = true
+		close(rc.c)
+		return
+	}
+	for {
+		select {
+		case rc.c <- ev:
+			return
+		default:
+		}
+		// Merge with the pending event.
+		select {
+		case old := <-rc.c:
+			ev |= old
+		default:
+		}
+	}
+}
+
+// ReadyC returns a channel receiving the readiness events (Readable,
+// Writable, Hangup, ErrorEvent) of File, so it can be used in select
+// statements. Events not yet received are merged. The channel is
+// closed when File is closed. Every call returns the same channel.
+//
+// Events are edge-triggered: once an event is received, the next one
+// for the same direction is only guaranteed after an operation on
+// File has found it not ready (e.g. a Read had to wait). The current
+// state of File is delivered when the channel is created.
+func (f *File) ReadyC() <-chan Events {
+	f.wm.Lock()
+	if f.readyC != nil {
+		f.wm.Unlock()
+		return f.readyC.c
+	}
+	rc := &readyChan{c: make(chan Events, 1)}
+	f.readyC = rc
+	f.wm.Unlock()
+	f.addWatcher(&watcher{fn: rc.deliver})
+	if f.isClosed() {
+		rc.deliver(f, 0)
+		return rc.c
+	}
+	var ev Events
+	if ok, _ := ready(f.fd, false); ok {
+		ev |= Readable
+	} else {
+		f.p.startTrack(f.fd, false)
+	}
+	if ok, _ := ready(f.fd, true); ok {
+		ev |= Writable
+	} else {
+		f.p.startTrack(f.fd, true)
+	}
+	if ev != 0 {
+		rc.deliver(f, ev)
+	}
+	return rc.c
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var (
+	registryLock sync.Mutex
+	registry     = map[string]*File{}
+)
+
+// Publish adds File to the registry under name, so it can be found by
+// Lookup (e.g. from a management interface). An empty name means the
+// last element of the File name ("ttyUSB0" for "/dev/ttyUSB0"). Names
+// are unique: if name is taken by another File ErrNameInUse is
+// returned. Publishing a File again moves it to the new name. Files
+// are removed from the registry when closed.
+func (f *File) Publish(name string) error {
+	if name == "" {
+		name = filepath.Base(f.name)
+	}
+	registryLock.Lock()
+	if o, ok := registry[name]; ok {
+		registryLock.Unlock()
+		if o == f {
+			return nil
+		}
+		return ErrNameInUse
+	}
+	if f.pubName != "" {
+		delete(registry, f.pubName)
+	}
+	registry[name] = f
+	f.pubName = name
+	registryLock.Unlock()
+	// Checked after publishing, since Close unpublishes once closed.
+	if f.isClosed() {
+		f.Unpublish()
+		return ErrClosed
+	}
+	return nil
+}
+
+// Unpublish removes File from the registry.
+func (f *File) Unpublish() {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if f.pubName != "" {
+		delete(registry, f.pubName)
+		f.pubName = ""
+	}
+}
+
+// Lookup returns the File published under name, or nil.
+func Lookup(name string) *File {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	return registry[name]
+}
+
+// Published returns the names in the registry, sorted.
+func Published() []string {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,99 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy configures the attempts of TransactRetry. The zero value
+// makes a single attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, including the first
+	// one.
+	MaxAttempts int
+	// Backoff is the pause before the second attempt. Each of the
+	// following pauses is Multiplier (2 if zero) times longer, up to
+	// MaxBackoff (if not zero).
+	Backoff    time.Duration
+	Multiplier float64
+	MaxBackoff time.Duration
+	// Validate, if set, checks every response, e.g. its checksum. Its
+	// errors are returned in an *OpError with Op "validate".
+	Validate func(resp []byte) error
+	// Retryable reports whether an attempt failed with err is worth
+	// retrying. If nil, DefaultRetryable is used.
+	Retryable func(err error) bool
+	// OnAttempt, if set, is called after every attempt (numbered from
+	// 1) with its duration and error, nil on success, e.g. to feed
+	// metrics.
+	OnAttempt func(attempt int, d time.Duration, err error)
+}
+
+// DefaultRetryable reports whether err is a transient failure of an
+// exchange: a timeout, a truncated response or a response rejected by
+// RetryPolicy.Validate. Errors like ErrClosed, ErrNoDevice or those of
+// a canceled context are final.
+func DefaultRetryable(err error) bool {
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrTruncated) {
+		return true
+	}
+	var oe *OpError
+	return errors.As(err, &oe) && oe.Op == "validate"
+}
+
+// TransactRetry runs Transact exchanges (see TransactContext) until
+// one succeeds, and passes policy.Validate, or the attempts permitted
+// by policy are used up, returning the result of the last one. It
+// gives up with ctx.Err() once ctx is done, including during the
+// pauses between attempts. opts.Timeout applies to each attempt.
+func (f *File) TransactRetry(ctx context.Context, req, resp []byte, opts *TransactOptions, policy *RetryPolicy) (int, error) {
+	if policy == nil {
+		policy = &RetryPolicy{}
+	}
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	mult := policy.Multiplier
+	if mult == 0 {
+		mult = 2
+	}
+	backoff := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		n, err := f.TransactContext(ctx, req, resp, opts)
+		if err == nil && policy.Validate != nil {
+			if verr := policy.Validate(resp[:n]); verr != nil {
+				err = &OpError{Op: "validate", Name: f.Name(), Err: verr}
+			}
+		}
+		if policy.OnAttempt != nil {
+			policy.OnAttempt(attempt, time.Since(start), err)
+		}
+		if err == nil || attempt >= policy.MaxAttempts || ctx.Err() != nil || !retryable(err) {
+			return n, err
+		}
+		if backoff > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return n, ctx.Err()
+			}
+			backoff = time.Duration(float64(backoff) * mult)
+			if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	}
+}
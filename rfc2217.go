@@ -0,0 +1,644 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+// Telnet protocol bytes.
+const (
+	telIAC  = 255
+	telDONT = 254
+	telDO   = 253
+	telWONT = 252
+	telWILL = 251
+	telSB   = 250
+	telSE   = 240
+
+	telOptBinary  = 0
+	telOptSGA     = 3
+	telOptComPort = 44
+)
+
+// RFC 2217 subnegotiation commands (client to server; the server
+// answers with the command code plus 100).
+const (
+	cpcSignature         = 0
+	cpcSetBaudrate       = 1
+	cpcSetDatasize       = 2
+	cpcSetParity         = 3
+	cpcSetStopsize       = 4
+	cpcSetControl        = 5
+	cpcNotifyLinestate   = 6
+	cpcNotifyModemstate  = 7
+	cpcFlowSuspend       = 8
+	cpcFlowResume        = 9
+	cpcSetLinestateMask  = 10
+	cpcSetModemstateMask = 11
+	cpcPurgeData         = 12
+	cpcServerOffset      = 100
+)
+
+// RFC 2217 SET-CONTROL values.
+const (
+	cpcCtlFlowRequest  = 0
+	cpcCtlFlowNone     = 1
+	cpcCtlFlowXonXoff  = 2
+	cpcCtlFlowHardware = 3
+	cpcCtlBreakRequest = 4
+	cpcCtlBreakOn      = 5
+	cpcCtlBreakOff     = 6
+	cpcCtlDTRRequest   = 7
+	cpcCtlDTROn        = 8
+	cpcCtlDTROff       = 9
+	cpcCtlRTSRequest   = 10
+	cpcCtlRTSOn        = 11
+	cpcCtlRTSOff       = 12
+)
+
+// Modem state bits reported by RFC 2217 servers (NOTIFY-MODEMSTATE).
+const (
+	ModemStateCTSDelta = 0x01
+	ModemStateDSRDelta = 0x02
+	ModemStateRIEdge   = 0x04
+	ModemStateCDDelta  = 0x08
+	ModemStateCTS      = 0x10
+	ModemStateDSR      = 0x20
+	ModemStateRI       = 0x40
+	ModemStateCD       = 0x80
+)
+
+// RFC2217State is the state of a serial port controlled through RFC
+// 2217. Zero Baud and DataBits mean unknown.
+type RFC2217State struct {
+	Baud        int
+	DataBits    int
+	Parity      Parity
+	StopBits    StopBits
+	FlowControl FlowControl
+	DTR         bool
+	RTS         bool
+	Break       bool
+}
+
+// telnetDecoder strips telnet commands from a data stream.
+type telnetDecoder struct {
+	state int
+	cmd   byte
+	sb    []byte
+	onCmd func(cmd, opt byte)
+	onSub func(data []byte)
+}
+
+const (
+	tsData = iota
+	tsIAC
+	tsOpt
+	tsSB
+	tsSBIAC
+)
+
+// maxSubneg limits the size of a subnegotiation.
+const maxSubneg = 256
+
+// decode removes the telnet commands from p, in place, dispatching
+// them to the handlers. It returns the number of data bytes left.
+func (d *telnetDecoder) decode(p []byte) int {
+	n := 0
+	for _, c := range p {
+		switch d.state {
+		case tsData:
+			if c == telIAC {
+				d.state = tsIAC
+			} else {
+				p[n] = c
+				n++
+			}
+		case tsIAC:
+			switch c {
+			case telIAC:
+				p[n] = c
+				n++
+				d.state = tsData
+			case telWILL, telWONT, telDO, telDONT:
+				d.cmd = c
+				d.state = tsOpt
+			case telSB:
+				d.sb = d.sb[:0]
+				d.state = tsSB
+			default:
+				d.state = tsData
+			}
+		case tsOpt:
+			if d.onCmd != nil {
+				d.onCmd(d.cmd, c)
+			}
+			d.state = tsData
+		case tsSB:
+			if c == telIAC {
+				d.state = tsSBIAC
+			} else if len(d.sb) < maxSubneg {
+				d.sb = append(d.sb, c)
+			}
+		case tsSBIAC:
+			switch c {
+			case telSE:
+				if d.onSub != nil {
+					d.onSub(d.sb)
+				}
+				d.state = tsData
+			case telIAC:
+				if len(d.sb) < maxSubneg {
+					d.sb = append(d.sb, c)
+				}
+				d.state = tsSB
+			default:
+				d.state = tsSB
+			}
+		}
+	}
+	return n
+}
+
+// telnetEscape doubles the IAC bytes of p.
+func telnetEscape(p []byte) []byte {
+	out := make([]byte, 0, len(p)+8)
+	for _, c := range p {
+		if c == telIAC {
+			out = append(out, telIAC)
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// comPortSubneg encodes a COM-PORT-OPTION subnegotiation.
+func comPortSubneg(code byte, val ...byte) []byte {
+	b := []byte{telIAC, telSB, telOptComPort, code}
+	b = append(b, telnetEscape(val)...)
+	return append(b, telIAC, telSE)
+}
+
+// telnetReply answers the option negotiation cmd/opt, accepting the
+// options in ok and refusing everything else. It returns nil if no
+// answer is needed.
+func telnetReply(cmd, opt byte, ok ...byte) []byte {
+	accepted := false
+	for _, o := range ok {
+		if o == opt {
+			accepted = true
+		}
+	}
+	switch cmd {
+	case telDO:
+		if !accepted {
+			return []byte{telIAC, telWONT, opt}
+		}
+	case telWILL:
+		if !accepted {
+			return []byte{telIAC, telDONT, opt}
+		}
+	}
+	return nil
+}
+
+func parityToRFC2217(p Parity) byte {
+	return byte(p) + 1
+}
+
+func parityFromRFC2217(v byte) Parity {
+	return Parity(v - 1)
+}
+
+func stopBitsToRFC2217(s StopBits) byte {
+	return byte(s) + 1
+}
+
+func stopBitsFromRFC2217(v byte) StopBits {
+	return StopBits(v - 1)
+}
+
+func flowToRFC2217(f FlowControl) byte {
+	return byte(f) + cpcCtlFlowNone
+}
+
+// RFC2217Client controls a remote serial port exported by an RFC 2217
+// (telnet COM-PORT-OPTION) server. Read and Write transfer the serial
+// data; the setters send the port configuration to the server.
+type RFC2217Client struct {
+	rw    io.ReadWriter
+	rm    sync.Mutex
+	wm    sync.Mutex
+	dec   telnetDecoder
+	sm    sync.Mutex
+	state RFC2217State // Reported by server, guarded by sm
+	modem byte         // Guarded by sm
+	line  byte         // Guarded by sm
+}
+
+// NewRFC2217Client starts the RFC 2217 negotiation on rw (usually a
+// net.Conn) and returns the client.
+func NewRFC2217Client(rw io.ReadWriter) (*RFC2217Client, error) {
+	c := &RFC2217Client{rw: rw}
+	c.dec.onCmd = c.command
+	c.dec.onSub = c.subneg
+	err := c.send([]byte{
+		telIAC, telWILL, telOptComPort,
+		telIAC, telWILL, telOptBinary,
+		telIAC, telDO, telOptBinary,
+		telIAC, telDO, telOptSGA,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *RFC2217Client) send(b []byte) error {
+	c.wm.Lock()
+	_, err := c.rw.Write(b)
+	c.wm.Unlock()
+	return err
+}
+
+func (c *RFC2217Client) command(cmd, opt byte) {
+	if r := telnetReply(cmd, opt, telOptBinary, telOptSGA, telOptComPort); r != nil {
+		c.send(r)
+	}
+}
+
+func (c *RFC2217Client) subneg(b []byte) {
+	if len(b) < 3 || b[0] != telOptComPort || b[1] < cpcServerOffset {
+		return
+	}
+	val := b[2:]
+	c.sm.Lock()
+	defer c.sm.Unlock()
+	switch b[1] - cpcServerOffset {
+	case cpcSetBaudrate:
+		if len(val) >= 4 {
+			c.state.Baud = int(binary.BigEndian.Uint32(val))
+		}
+	case cpcSetDatasize:
+		c.state.DataBits = int(val[0])
+	case cpcSetParity:
+		c.state.Parity = parityFromRFC2217(val[0])
+	case cpcSetStopsize:
+		c.state.StopBits = stopBitsFromRFC2217(val[0])
+	case cpcSetControl:
+		switch val[0] {
+		case cpcCtlFlowNone, cpcCtlFlowXonXoff, cpcCtlFlowHardware:
+			c.state.FlowControl = FlowControl(val[0] - cpcCtlFlowNone)
+		case cpcCtlBreakOn, cpcCtlBreakOff:
+			c.state.Break = val[0] == cpcCtlBreakOn
+		case cpcCtlDTROn, cpcCtlDTROff:
+			c.state.DTR = val[0] == cpcCtlDTROn
+		case cpcCtlRTSOn, cpcCtlRTSOff:
+			c.state.RTS = val[0] == cpcCtlRTSOn
+		}
+	case cpcNotifyModemstate:
+		c.modem = val[0]
+	case cpcNotifyLinestate:
+		c.line = val[0]
+	}
+}
+
+// Read reads serial data coming from the remote port.
+func (c *RFC2217Client) Read(p []byte) (int, error) {
+	c.rm.Lock()
+	defer c.rm.Unlock()
+	for {
+		n, err := c.rw.Read(p)
+		n = c.dec.decode(p[:n])
+		if n > 0 || err != nil || len(p) == 0 {
+			return n, err
+		}
+	}
+}
+
+// Write writes serial data to the remote port.
+func (c *RFC2217Client) Write(p []byte) (int, error) {
+	if err := c.send(telnetEscape(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// SetBaudRate sets the baudrate of the remote port.
+func (c *RFC2217Client) SetBaudRate(baud int) error {
+	var v [4]byte
+	binary.BigEndian.PutUint32(v[:], uint32(baud))
+	return c.send(comPortSubneg(cpcSetBaudrate, v[:]...))
+}
+
+// SetDataBits sets the number of data bits (5 to 8) of the remote port.
+func (c *RFC2217Client) SetDataBits(bits int) error {
+	return c.send(comPortSubneg(cpcSetDatasize, byte(bits)))
+}
+
+// SetParity sets the parity of the remote port.
+func (c *RFC2217Client) SetParity(p Parity) error {
+	return c.send(comPortSubneg(cpcSetParity, parityToRFC2217(p)))
+}
+
+// SetStopBits sets the stop bits of the remote port.
+func (c *RFC2217Client) SetStopBits(s StopBits) error {
+	return c.send(comPortSubneg(cpcSetStopsize, stopBitsToRFC2217(s)))
+}
+
+// SetFlowControl sets the flow control of the remote port.
+func (c *RFC2217Client) SetFlowControl(f FlowControl) error {
+	return c.send(comPortSubneg(cpcSetControl, flowToRFC2217(f)))
+}
+
+func (c *RFC2217Client) setControl(on bool, vOn, vOff byte) error {
+	if on {
+		return c.send(comPortSubneg(cpcSetControl, vOn))
+	}
+	return c.send(comPortSubneg(cpcSetControl, vOff))
+}
+
+// SetDTR sets or clears the DTR line of the remote port.
+func (c *RFC2217Client) SetDTR(on bool) error {
+	return c.setControl(on, cpcCtlDTROn, cpcCtlDTROff)
+}
+
+// SetRTS sets or clears the RTS line of the remote port.
+func (c *RFC2217Client) SetRTS(on bool) error {
+	return c.setControl(on, cpcCtlRTSOn, cpcCtlRTSOff)
+}
+
+// SetBreak starts or stops sending a break on the remote port.
+func (c *RFC2217Client) SetBreak(on bool) error {
+	return c.setControl(on, cpcCtlBreakOn, cpcCtlBreakOff)
+}
+
+// Purge discards the receive and/or transmit buffers of the remote port.
+func (c *RFC2217Client) Purge(rx, tx bool) error {
+	var v byte
+	if rx {
+		v |= 1
+	}
+	if tx {
+		v |= 2
+	}
+	if v == 0 {
+		return nil
+	}
+	return c.send(comPortSubneg(cpcPurgeData, v))
+}
+
+// State returns the port state as last acknowledged by the server.
+// Answers are processed by Read, so they are only seen while some
+// goroutine is reading.
+func (c *RFC2217Client) State() RFC2217State {
+	c.sm.Lock()
+	defer c.sm.Unlock()
+	return c.state
+}
+
+// ModemState returns the last modem state (ModemStateXXX bits)
+// notified by the server.
+func (c *RFC2217Client) ModemState() byte {
+	c.sm.Lock()
+	defer c.sm.Unlock()
+	return c.modem
+}
+
+// LineState returns the last line state (LSR bits) notified by the
+// server.
+func (c *RFC2217Client) LineState() byte {
+	c.sm.Lock()
+	defer c.sm.Unlock()
+	return c.line
+}
+
+// RFC2217Server exports a serial port to RFC 2217 clients.
+type RFC2217Server struct {
+	// Port is the local serial port. If it supports deadlines (like
+	// File) Serve can abort a pending Read when the client leaves.
+	Port io.ReadWriter
+	// State is the port state reported to clients before any change.
+	State RFC2217State
+	// Apply is called with the new state every time a client changes
+	// it. If it fails the previous state is kept and reported back.
+	// A nil Apply accepts every change without doing anything.
+	Apply func(s RFC2217State) error
+	// Purge, if not nil, discards the port buffers on client request.
+	Purge func(rx, tx bool) error
+
+	sm sync.Mutex
+}
+
+type rfc2217Session struct {
+	s   *RFC2217Server
+	rw  io.ReadWriter
+	wm  sync.Mutex
+	err error // First write error, guarded by wm
+}
+
+func (ss *rfc2217Session) send(b []byte) error {
+	ss.wm.Lock()
+	defer ss.wm.Unlock()
+	if ss.err != nil {
+		return ss.err
+	}
+	_, ss.err = ss.rw.Write(b)
+	return ss.err
+}
+
+func (ss *rfc2217Session) command(cmd, opt byte) {
+	if r := telnetReply(cmd, opt, telOptBinary, telOptSGA, telOptComPort); r != nil {
+		ss.send(r)
+	}
+}
+
+func (ss *rfc2217Session) subneg(b []byte) {
+	if len(b) < 2 || b[0] != telOptComPort {
+		return
+	}
+	s := ss.s
+	code, val := b[1], b[2:]
+	var arg byte
+	if len(val) > 0 {
+		arg = val[0]
+	}
+	s.sm.Lock()
+	defer s.sm.Unlock()
+	st := s.State
+	switch code {
+	case cpcSignature:
+		ss.send(comPortSubneg(cpcSignature+cpcServerOffset, []byte("jaracil/poll")...))
+		return
+	case cpcSetBaudrate:
+		if len(val) >= 4 {
+			if v := binary.BigEndian.Uint32(val); v != 0 {
+				st.Baud = int(v)
+			}
+		}
+	case cpcSetDatasize:
+		if arg >= 5 && arg <= 8 {
+			st.DataBits = int(arg)
+		}
+	case cpcSetParity:
+		if arg >= 1 && arg <= 5 {
+			st.Parity = parityFromRFC2217(arg)
+		}
+	case cpcSetStopsize:
+		if arg >= 1 && arg <= 3 {
+			st.StopBits = stopBitsFromRFC2217(arg)
+		}
+	case cpcSetControl:
+		switch arg {
+		case cpcCtlFlowNone, cpcCtlFlowXonXoff, cpcCtlFlowHardware:
+			st.FlowControl = FlowControl(arg - cpcCtlFlowNone)
+		case cpcCtlBreakOn, cpcCtlBreakOff:
+			st.Break = arg == cpcCtlBreakOn
+		case cpcCtlDTROn, cpcCtlDTROff:
+			st.DTR = arg == cpcCtlDTROn
+		case cpcCtlRTSOn, cpcCtlRTSOff:
+			st.RTS = arg == cpcCtlRTSOn
+		}
+	case cpcSetLinestateMask, cpcSetModemstateMask:
+		ss.send(comPortSubneg(code+cpcServerOffset, arg))
+		return
+	case cpcPurgeData:
+		if s.Purge != nil {
+			s.Purge(arg&1 != 0, arg&2 != 0)
+		}
+		ss.send(comPortSubneg(code+cpcServerOffset, arg))
+		return
+	default:
+		return
+	}
+	if st != s.State && (s.Apply == nil || s.Apply(st) == nil) {
+		s.State = st
+	}
+	st = s.State
+	// Report the resulting value.
+	switch code {
+	case cpcSetBaudrate:
+		var v [4]byte
+		binary.BigEndian.PutUint32(v[:], uint32(st.Baud))
+		ss.send(comPortSubneg(code+cpcServerOffset, v[:]...))
+	case cpcSetDatasize:
+		ss.send(comPortSubneg(code+cpcServerOffset, byte(st.DataBits)))
+	case cpcSetParity:
+		ss.send(comPortSubneg(code+cpcServerOffset, parityToRFC2217(st.Parity)))
+	case cpcSetStopsize:
+		ss.send(comPortSubneg(code+cpcServerOffset, stopBitsToRFC2217(st.StopBits)))
+	case cpcSetControl:
+		v := arg
+		switch arg {
+		case cpcCtlFlowRequest, cpcCtlFlowNone, cpcCtlFlowXonXoff, cpcCtlFlowHardware:
+			v = flowToRFC2217(st.FlowControl)
+		case cpcCtlBreakRequest, cpcCtlBreakOn, cpcCtlBreakOff:
+			v = cpcCtlBreakOff
+			if st.Break {
+				v = cpcCtlBreakOn
+			}
+		case cpcCtlDTRRequest, cpcCtlDTROn, cpcCtlDTROff:
+			v = cpcCtlDTROff
+			if st.DTR {
+				v = cpcCtlDTROn
+			}
+		case cpcCtlRTSRequest, cpcCtlRTSOn, cpcCtlRTSOff:
+			v = cpcCtlRTSOff
+			if st.RTS {
+				v = cpcCtlRTSOn
+			}
+		}
+		ss.send(comPortSubneg(code+cpcServerOffset, v))
+	}
+}
+
+// Serve relays data between the client connected through rw (usually
+// a net.Conn) and Port, handling the RFC 2217 commands, until rw or
+// Port fail, and returns the first error (nil if the client closed the
+// connection). If Port fails rw is closed, if it's an io.Closer. A
+// Server serves one client at a time.
+func (s *RFC2217Server) Serve(rw io.ReadWriter) error {
+	ss := &rfc2217Session{s: s, rw: rw}
+	dec := telnetDecoder{onCmd: ss.command, onSub: ss.subneg}
+	err := ss.send([]byte{
+		telIAC, telDO, telOptComPort,
+		telIAC, telWILL, telOptBinary,
+		telIAC, telDO, telOptBinary,
+		telIAC, telWILL, telOptSGA,
+	})
+	if err != nil {
+		return err
+	}
+	var stopped bool
+	var stopM sync.Mutex
+	done := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := s.Port.Read(buf)
+			if n > 0 {
+				if werr := ss.send(telnetEscape(buf[:n])); werr != nil {
+					done <- werr
+					return
+				}
+			}
+			if err != nil {
+				stopM.Lock()
+				st := stopped
+				stopM.Unlock()
+				if st {
+					err = nil
+				}
+				done <- err
+				return
+			}
+		}
+	}()
+	netDone := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		var err error
+		for err == nil {
+			var n int
+			n, err = rw.Read(buf)
+			if n = dec.decode(buf[:n]); n > 0 {
+				if _, werr := s.Port.Write(buf[:n]); werr != nil {
+					err = werr
+				}
+			}
+		}
+		netDone <- err
+	}()
+	select {
+	case err = <-netDone:
+		if err == io.EOF {
+			err = nil
+		}
+		// Stop the Port reader.
+		stopM.Lock()
+		stopped = true
+		stopM.Unlock()
+		if SetReadDeadline(s.Port, time.Unix(1, 0)) == nil {
+			if rerr := <-done; err == nil {
+				err = rerr
+			}
+			SetReadDeadline(s.Port, time.Time{})
+		}
+	case err = <-done:
+		// Port (or writing to rw) failed, stop the client side.
+		if c, ok := rw.(io.Closer); ok {
+			c.Close()
+			<-netDone
+		} else if SetReadDeadline(rw, time.Unix(1, 0)) == nil {
+			<-netDone
+		}
+	}
+	return err
+}
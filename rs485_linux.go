@@ -0,0 +1,92 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"time"
+	"unsafe"
+)
+
+// RS485Config is the RS-485 mode configuration of a serial port.
+type RS485Config struct {
+	Enabled      bool // RS-485 mode enabled
+	RTSOnSend    bool // RTS asserted while sending
+	RTSAfterSend bool // RTS asserted after sending
+	RxDuringTx   bool // Receive while transmitting (full duplex)
+	TerminateBus bool // Enable the bus termination, if supported
+	// Delays between RTS changes and the transmission, with
+	// millisecond resolution.
+	DelayRTSBeforeSend time.Duration
+	DelayRTSAfterSend  time.Duration
+}
+
+// serialRS485 is the Linux struct serial_rs485.
+type serialRS485 struct {
+	flags              uint32
+	delayRTSBeforeSend uint32 // Milliseconds
+	delayRTSAfterSend  uint32 // Milliseconds
+	padding            [5]uint32
+}
+
+// serial_rs485 flags.
+const (
+	serRS485Enabled      = 1 << 0
+	serRS485RTSOnSend    = 1 << 1
+	serRS485RTSAfterSend = 1 << 2
+	serRS485RxDuringTx   = 1 << 4
+	serRS485TerminateBus = 1 << 5
+)
+
+// SetRS485 configures the RS-485 mode of the serial port
+// (TIOCSRS485), so the driver drives the transceiver. Drivers without
+// RS-485 support fail with ENOTTY.
+func (f *File) SetRS485(cfg RS485Config) error {
+	var rs serialRS485
+	if cfg.Enabled {
+		rs.flags |= serRS485Enabled
+	}
+	if cfg.RTSOnSend {
+		rs.flags |= serRS485RTSOnSend
+	}
+	if cfg.RTSAfterSend {
+		rs.flags |= serRS485RTSAfterSend
+	}
+	if cfg.RxDuringTx {
+		rs.flags |= serRS485RxDuringTx
+	}
+	if cfg.TerminateBus {
+		rs.flags |= serRS485TerminateBus
+	}
+	rs.delayRTSBeforeSend = uint32(cfg.DelayRTSBeforeSend / time.Millisecond)
+	rs.delayRTSAfterSend = uint32(cfg.DelayRTSAfterSend / time.Millisecond)
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	return ioctl(f.fd, ioctlSetRS485, uintptr(unsafe.Pointer(&rs)))
+}
+
+// RS485 returns the RS-485 mode configuration of the serial port
+// (TIOCGRS485).
+func (f *File) RS485() (RS485Config, error) {
+	if err := f.Lock(); err != nil {
+		return RS485Config{}, err
+	}
+	defer f.Unlock()
+	var rs serialRS485
+	if err := ioctl(f.fd, ioctlGetRS485, uintptr(unsafe.Pointer(&rs))); err != nil {
+		return RS485Config{}, err
+	}
+	return RS485Config{
+		Enabled:            rs.flags&serRS485Enabled != 0,
+		RTSOnSend:          rs.flags&serRS485RTSOnSend != 0,
+		RTSAfterSend:       rs.flags&serRS485RTSAfterSend != 0,
+		RxDuringTx:         rs.flags&serRS485RxDuringTx != 0,
+		TerminateBus:       rs.flags&serRS485TerminateBus != 0,
+		DelayRTSBeforeSend: time.Duration(rs.delayRTSBeforeSend) * time.Millisecond,
+		DelayRTSAfterSend:  time.Duration(rs.delayRTSAfterSend) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"io"
+	"sync"
+)
+
+// WriteScheduler shares an io.Writer (usually a File carrying a
+// multiplexed link) among several logical channels. Pending writes are
+// served in deficit round-robin order: in every round each channel
+// with data pending may write up to its quantum of bytes, so a chatty
+// channel can't starve the others.
+type WriteScheduler struct {
+	w      io.Writer
+	m      sync.Mutex
+	cond   *sync.Cond
+	active []*WriteChannel // Channels with writes pending, in service order
+	closed bool
+	err    error // Sticky error of the underlying Writer
+}
+
+// WriteChannel is a logical channel of a WriteScheduler.
+type WriteChannel struct {
+	s       *WriteScheduler
+	quantum int
+	deficit int
+	q       []*schedReq
+	closed  bool
+}
+
+type schedReq struct {
+	p    []byte
+	n    int
+	err  error
+	done chan struct{}
+}
+
+// NewWriteScheduler returns a WriteScheduler writing to w and starts
+// its goroutine.
+func NewWriteScheduler(w io.Writer) *WriteScheduler {
+	s := &WriteScheduler{w: w}
+	s.cond = sync.NewCond(&s.m)
+	go s.loop()
+	return s
+}
+
+// Channel returns a new channel which may write up to quantum bytes
+// (at least 1) per round. Channels with a larger quantum get a
+// proportionally larger share of the bandwidth.
+func (s *WriteScheduler) Channel(quantum int) *WriteChannel {
+	if quantum < 1 {
+		quantum = 1
+	}
+	return &WriteChannel{s: s, quantum: quantum}
+}
+
+// Close stops the scheduler. Pending writes fail with ErrClosed. The
+// underlying Writer is not closed.
+func (s *WriteScheduler) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	s.closed = true
+	s.cond.Broadcast()
+	return nil
+}
+
+// Write queues p and blocks until it has been written to the
+// underlying Writer, which may be interleaved with the writes of other
+// channels. If the underlying Writer fails, the error is returned by
+// every pending and further Write.
+func (c *WriteChannel) Write(p []byte) (int, error) {
+	s := c.s
+	s.m.Lock()
+	switch {
+	case s.err != nil:
+		s.m.Unlock()
+		return 0, s.err
+	case s.closed || c.closed:
+		s.m.Unlock()
+		return 0, ErrClosed
+	case len(p) == 0:
+		s.m.Unlock()
+		return 0, nil
+	}
+	r := &schedReq{p: p, done: make(chan struct{})}
+	c.q = append(c.q, r)
+	if len(c.q) == 1 {
+		s.active = append(s.active, c)
+		s.cond.Signal()
+	}
+	s.m.Unlock()
+	<-r.done
+	return r.n, r.err
+}
+
+// Close closes the channel. Writes already queued are still served.
+func (c *WriteChannel) Close() error {
+	c.s.m.Lock()
+	defer c.s.m.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	c.closed = true
+	return nil
+}
+
+// fail completes every pending write with err. s.m must be held.
+func (s *WriteScheduler) fail(err error) {
+	for _, c := range s.active {
+		for _, r := range c.q {
+			r.err = err
+			close(r.done)
+		}
+		c.q = nil
+		c.deficit = 0
+	}
+	s.active = nil
+}
+
+func (s *WriteScheduler) loop() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for {
+		for len(s.active) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.fail(ErrClosed)
+			return
+		}
+		c := s.active[0]
+		s.active = s.active[1:]
+		c.deficit += c.quantum
+		for c.deficit > 0 && len(c.q) > 0 {
+			r := c.q[0]
+			chunk := r.p[r.n:]
+			if len(chunk) > c.deficit {
+				chunk = chunk[:c.deficit]
+			}
+			s.m.Unlock()
+			n, err := s.w.Write(chunk)
+			s.m.Lock()
+			r.n += n
+			c.deficit -= n
+			if err == nil && n < len(chunk) {
+				err = io.ErrShortWrite
+			}
+			if err != nil {
+				s.err = err
+				r.err = err
+				close(r.done)
+				c.q = c.q[1:]
+				s.active = append(s.active, c)
+				s.fail(err)
+				s.closed = true
+				return
+			}
+			if r.n == len(r.p) {
+				close(r.done)
+				c.q = c.q[1:]
+			}
+		}
+		if len(c.q) > 0 {
+			s.active = append(s.active, c)
+		} else {
+			c.deficit = 0
+		}
+	}
+}
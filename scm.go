@@ -0,0 +1,139 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"fmt"
+	"io"
+	"syscall"
+	"unsafe"
+)
+
+// maxRights is the maximum number of descriptors received by
+// RecvFiles, the kernel limit on Linux (SCM_MAX_FD).
+const maxRights = 253
+
+// SendMsg sends p along with the ancillary data oob, as built by
+// syscall.UnixRights (to pass descriptors) or syscall.UnixCredentials
+// (to pass credentials, Linux only), to the address to (nil on
+// connected sockets). On stream sockets n may be less than len(p); the
+// ancillary data is sent with the first byte. The write deadline
+// applies.
+func (f *File) SendMsg(p, oob []byte, to syscall.Sockaddr) (n int, err error) {
+	f.w.m.Lock()
+	n, err = f.sysio(true, func(fd int) (int, error) {
+		return syscall.SendmsgN(fd, p, oob, to, 0)
+	})
+	f.w.m.Unlock()
+	if err != nil {
+		err = f.opError("send", err)
+	}
+	return n, err
+}
+
+// RecvMsg receives data into p and ancillary data into oob, returning
+// their lengths, the message flags (MSG_TRUNC, MSG_CTRUNC, etc.) and
+// the address of the sender on unconnected sockets. Use
+// syscall.ParseSocketControlMessage to decode oob. Received descriptors
+// are close-on-exec. The read deadline applies.
+func (f *File) RecvMsg(p, oob []byte) (n, oobn, flags int, from syscall.Sockaddr, err error) {
+	f.r.m.Lock()
+	n, err = f.sysio(false, func(fd int) (int, error) {
+		var n int
+		var err error
+		n, oobn, flags, from, err = syscall.Recvmsg(fd, p, oob, cmsgCloexec)
+		return n, err
+	})
+	f.r.m.Unlock()
+	if err != nil {
+		err = f.opError("receive", err)
+	}
+	return n, oobn, flags, from, err
+}
+
+// SendFiles sends p along with the descriptors of files over a unix
+// socket. p must not be empty. The files are still open after
+// SendFiles; the peer gets duplicates of their descriptors.
+func (f *File) SendFiles(p []byte, files ...*File) (n int, err error) {
+	fds := make([]int, 0, len(files))
+	for _, x := range files {
+		if x.isClosed() {
+			return 0, ErrClosed
+		}
+		fds = append(fds, x.fd)
+	}
+	return f.SendMsg(p, syscall.UnixRights(fds...), nil)
+}
+
+// RecvFiles receives data into p and the descriptors sent with it over
+// a unix socket, returned as new Files served by the same Poller as
+// File and named after it ("<name>:0", "<name>:1", etc.). n == 0 with
+// no error and no files means the peer closed the connection.
+func (f *File) RecvFiles(p []byte) (n int, files []*File, err error) {
+	oob := make([]byte, syscall.CmsgSpace(maxRights*4))
+	n, oobn, _, _, err := f.RecvMsg(p, oob)
+	if err != nil {
+		return 0, nil, err
+	}
+	fds, err := parseRights(oob[:oobn])
+	if err != nil {
+		closeFds(fds)
+		return 0, nil, f.opError("receive", err)
+	}
+	for i, fd := range fds {
+		syscall.CloseOnExec(fd)
+		x, err := f.p.NewFile(uintptr(fd), fmt.Sprintf("%s:%d", f.name, i))
+		if err != nil {
+			for _, x := range files {
+				x.Close()
+			}
+			closeFds(fds[i:])
+			return 0, nil, err
+		}
+		files = append(files, x)
+	}
+	if n == 0 && len(files) == 0 && len(p) != 0 {
+		return 0, nil, io.EOF
+	}
+	return n, files, nil
+}
+
+// parseRights returns the descriptors carried by the SCM_RIGHTS
+// control messages in oob. The kernel has already installed them, so
+// the messages are walked one by one and, on a malformed one, those
+// parsed so far are returned along with the error, for the caller to
+// close.
+func parseRights(oob []byte) ([]int, error) {
+	var fds []int
+	hdrLen := syscall.CmsgLen(0)
+	for i := 0; i+hdrLen <= len(oob); {
+		h := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[i]))
+		l := int(h.Len)
+		if l < hdrLen || l > len(oob)-i {
+			return fds, syscall.EINVAL
+		}
+		if h.Level == syscall.SOL_SOCKET && h.Type == syscall.SCM_RIGHTS {
+			m := syscall.SocketControlMessage{Header: *h, Data: oob[i+hdrLen : i+l]}
+			rights, err := syscall.ParseUnixRights(&m)
+			if err != nil {
+				return fds, err
+			}
+			fds = append(fds, rights...)
+		}
+		i += syscall.CmsgSpace(l - hdrLen)
+	}
+	return fds, nil
+}
+
+// closeFds closes the descriptors fds.
+func closeFds(fds []int) {
+	for _, fd := range fds {
+		syscall.Close(fd)
+	}
+}
@@ -0,0 +1,10 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+// cmsgCloexec is not supported by darwin. Received descriptors are made
+// close-on-exec once received.
+const cmsgCloexec = 0
@@ -0,0 +1,93 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// newSocketpair returns the ends of a unix datagram socket pair served
+// by the default Poller.
+func newSocketpair(tb testing.TB) (a, b *File) {
+	tb.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if a, err = NewFile(uintptr(fds[0]), "a"); err != nil {
+		tb.Fatal(err)
+	}
+	if b, err = NewFile(uintptr(fds[1]), "b"); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, b
+}
+
+// TestMsgErrorsNoAllocs checks the message oriented calls return their
+// errors wrapped in the pre-built OpErrors, like Read and Write.
+func TestMsgErrorsNoAllocs(t *testing.T) {
+	a, b := newSocketpair(t)
+	a.SetReadDeadline(time.Unix(1, 0))
+	b.Close()
+	buf := make([]byte, 1)
+	calls := []struct {
+		op   string
+		want error
+		fn   func() error
+	}{
+		{"receive", ErrTimeout, func() error { _, _, err := a.ReadFromAddr(buf); return err }},
+		{"receive", ErrTimeout, func() error { _, _, err := a.ReadPacket(buf); return err }},
+		{"receive", ErrTimeout, func() error { _, _, _, _, err := a.RecvMsg(buf, nil); return err }},
+		{"send", ErrClosed, func() error { _, err := b.WriteToAddr(buf, nil); return err }},
+		{"send", ErrClosed, func() error { _, err := b.WritePacket(buf); return err }},
+		{"send", ErrClosed, func() error { _, err := b.SendMsg(buf, nil, nil); return err }},
+	}
+	for i, c := range calls {
+		var oe *OpError
+		if err := c.fn(); !errors.As(err, &oe) || oe.Op != c.op || oe.Err != c.want {
+			t.Fatalf("call %d: err = %#v, want %s %v", i, err, c.op, c.want)
+		}
+		if n := testing.AllocsPerRun(100, func() { c.fn() }); n != 0 {
+			t.Fatalf("call %d: %s error allocates %v times", i, c.op, n)
+		}
+	}
+}
+
+func TestParseRightsMalformed(t *testing.T) {
+	fd, err := syscall.Dup(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fd)
+	oob := syscall.UnixRights(fd, fd)
+	// A trailing header whose length overruns the buffer.
+	bad := make([]byte, syscall.CmsgSpace(4))
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&bad[0]))
+	h.Level, h.Type = syscall.SOL_SOCKET, syscall.SCM_RIGHTS
+	h.SetLen(len(bad) + 8)
+	fds, err := parseRights(append(oob, bad...))
+	if err == nil {
+		t.Fatal("malformed control message accepted")
+	}
+	if len(fds) != 2 || fds[0] != fd || fds[1] != fd {
+		t.Fatalf("descriptors parsed before the error = %v", fds)
+	}
+	fds, err = parseRights(syscall.UnixRights(fd))
+	if err != nil || len(fds) != 1 || fds[0] != fd {
+		t.Fatalf("parseRights = %v, %v", fds, err)
+	}
+}
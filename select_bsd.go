@@ -0,0 +1,21 @@
+//go:build (darwin || dragonfly || freebsd || netbsd || openbsd) && select
+// +build darwin dragonfly freebsd netbsd openbsd
+// +build select
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// sysSelect calls select(2). The BSD syscall.Select doesn't return the
+// number of ready descriptors, so they are counted from the sets.
+func sysSelect(n int, r, w, e *syscall.FdSet, tv *syscall.Timeval, rs, ws, es *FdSet) (int, error) {
+	if err := syscall.Select(n, r, w, e, tv); err != nil {
+		return 0, err
+	}
+	return rs.count() + ws.count() + es.count(), nil
+}
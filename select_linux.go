@@ -0,0 +1,15 @@
+//go:build select
+// +build select
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+func sysSelect(n int, r, w, e *syscall.FdSet, tv *syscall.Timeval, _, _, _ *FdSet) (int, error) {
+	return syscall.Select(n, r, w, e, tv)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"io"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// sendfileChunk is the maximum number of bytes sent per sendfile(2)
+// call, so deadlines and Close are checked regularly.
+const sendfileChunk = 4 << 20
+
+// sendfileFrom implements ReadFrom with sendfile(2) when r (an
+// *os.File, a File or an io.LimitedReader wrapping them) is a regular
+// file and f is a socket. The data is read from the current offset of
+// r, which is advanced. The write deadline of f applies. handled is
+// false if sendfile(2) can't be used.
+func (f *File) sendfileFrom(r io.Reader) (written int64, handled bool, err error) {
+	n := int64(-1)
+	lr, ok := r.(*io.LimitedReader)
+	if ok {
+		n, r = lr.N, lr.R
+		if n <= 0 {
+			return 0, true, nil
+		}
+	}
+	var src int
+	var srcF *File
+	switch x := r.(type) {
+	case *os.File:
+		src = int(x.Fd())
+		defer runtime.KeepAlive(x)
+	case *File:
+		x.r.m.Lock()
+		defer x.r.m.Unlock()
+		src, srcF = x.fd, x
+	default:
+		return 0, false, nil
+	}
+	if fileType(src) != syscall.S_IFREG || fileType(f.fd) != syscall.S_IFSOCK {
+		return 0, false, nil
+	}
+	f.w.m.Lock()
+	defer f.w.m.Unlock()
+	for n < 0 || written < n {
+		chunk := sendfileChunk
+		if n >= 0 && n-written < int64(chunk) {
+			chunk = int(n - written)
+		}
+		var nn int
+		nn, err = f.sysio(true, func(fd int) (int, error) {
+			if srcF != nil {
+				// Keep src from being closed, and its number
+				// reused, during the call.
+				srcF.io.RLock()
+				defer srcF.io.RUnlock()
+				if srcF.closed {
+					return 0, ErrClosed
+				}
+			}
+			return syscall.Sendfile(fd, src, nil, chunk)
+		})
+		written += int64(nn)
+		if err != nil {
+			err = f.opError("sendfile", err)
+			break
+		}
+		if nn == 0 {
+			break // End of file
+		}
+	}
+	if lr != nil {
+		lr.N -= written
+	}
+	return written, true, err
+}
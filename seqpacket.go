@@ -0,0 +1,75 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"io"
+	"syscall"
+	"time"
+)
+
+// SeqPacketPair returns a pair of connected SOCK_SEQPACKET unix
+// sockets.
+func SeqPacketPair() (*File, *File, error) {
+	fds, err := sysSocketpair(syscall.AF_UNIX, syscall.SOCK_SEQPACKET, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newFilePair(fds, "seqpacket", "seqpacket")
+}
+
+// DialSeqPacket connects to the SOCK_SEQPACKET unix socket bound to
+// path.
+func DialSeqPacket(path string) (*File, error) {
+	return DialSockaddr(syscall.AF_UNIX, syscall.SOCK_SEQPACKET, 0, &syscall.SockaddrUnix{Name: path}, time.Time{})
+}
+
+// ReadPacket reads a single message from a message oriented socket
+// (SOCK_SEQPACKET, SOCK_DGRAM). If the message doesn't fit in p, the
+// first len(p) bytes are returned, the rest is discarded and the error
+// is ErrTruncated. eor reports whether the message carried the end of
+// record mark (MSG_EOR). When the peer has closed the connection
+// ReadPacket returns io.EOF; note that on connected sockets an empty
+// message can't be told apart from it.
+func (f *File) ReadPacket(p []byte) (n int, eor bool, err error) {
+	var flags int
+	f.r.m.Lock()
+	n, err = f.sysio(false, func(fd int) (int, error) {
+		var n int
+		var err error
+		n, _, flags, _, err = syscall.Recvmsg(fd, p, nil, 0)
+		return n, err
+	})
+	f.r.m.Unlock()
+	if err != nil {
+		return 0, false, f.opError("receive", err)
+	}
+	if n == 0 && len(p) != 0 {
+		return 0, false, io.EOF
+	}
+	eor = flags&syscall.MSG_EOR != 0
+	if flags&syscall.MSG_TRUNC != 0 {
+		err = ErrTruncated
+	}
+	return n, eor, err
+}
+
+// WritePacket writes p as a single message. Unlike Write, a short write
+// is not retried since it would split the message.
+func (f *File) WritePacket(p []byte) (n int, err error) {
+	f.w.m.Lock()
+	n, err = f.sysio(true, func(fd int) (int, error) {
+		return syscall.SendmsgN(fd, p, nil, nil, 0)
+	})
+	f.w.m.Unlock()
+	if err != nil {
+		err = f.opError("send", err)
+	}
+	return n, err
+}
@@ -0,0 +1,224 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Config is the line configuration of a serial port.
+type Config struct {
+	Baud        int // Zero keeps the current baudrate. See SetBaudrate
+	DataBits    int // 5 to 8. Zero means 8
+	Parity      Parity
+	StopBits    StopBits // StopBits1Half requires 5 data bits
+	FlowControl FlowControl
+}
+
+// OpenSerial opens the serial port at path, puts it in raw mode with
+// the line configuration cfg and returns it as a File served by the
+// default Poller. The port doesn't become the controlling terminal of
+// the process. The quirks registered for its driver, if any, are
+// applied (see RegisterQuirks).
+func OpenSerial(path string, cfg Config) (*File, error) {
+	f, err := Open(path, syscall.O_RDWR|syscall.O_NOCTTY)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.configure(cfg, false); err != nil {
+		f.Close()
+		return nil, &OpError{Op: "open", Name: path, Err: err}
+	}
+	f.applyDriverQuirks()
+	return f, nil
+}
+
+// SetConfig puts the serial port in raw mode with the line
+// configuration cfg. Like Reconfigure, it waits for pending output to
+// be transmitted first.
+func (f *File) SetConfig(cfg Config) error {
+	f.w.m.Lock()
+	defer f.w.m.Unlock()
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	return f.configure(cfg, true)
+}
+
+// SetBaudrate changes the baudrate of the serial port, once pending
+// output has been transmitted. Besides the standard rates, any rate
+// supported by the hardware (e.g. 250000 or 10400) can be set; Linux
+// uses termios2 (BOTHER) for them.
+func (f *File) SetBaudrate(baud int) error {
+	f.w.m.Lock()
+	defer f.w.m.Unlock()
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	return setBaudrate(f.fd, baud)
+}
+
+// configure applies cfg. The caller holds the File locks. If drain is
+// true the settings are applied once pending output has been
+// transmitted.
+func (f *File) configure(cfg Config, drain bool) error {
+	var t syscall.Termios
+	if err := ioctl(f.fd, ioctlGetTermios, uintptr(unsafe.Pointer(&t))); err != nil {
+		return err
+	}
+	if err := cfg.apply(&t); err != nil {
+		return err
+	}
+	req := uint(ioctlSetTermios)
+	if drain {
+		req = ioctlSetTermiosDrain
+	}
+	if err := ioctl(f.fd, req, uintptr(unsafe.Pointer(&t))); err != nil {
+		return err
+	}
+	if cfg.Baud == 0 {
+		return nil
+	}
+	return setBaudrate(f.fd, cfg.Baud)
+}
+
+// setBaudrate sets the baudrate of fd, falling back to setCustomSpeed
+// for non-standard rates.
+func setBaudrate(fd, baud int) error {
+	var t syscall.Termios
+	if err := ioctl(fd, ioctlGetTermios, uintptr(unsafe.Pointer(&t))); err != nil {
+		return err
+	}
+	if err := setSpeed(&t, baud); err != nil {
+		return setCustomSpeed(fd, baud)
+	}
+	return ioctl(fd, ioctlSetTermiosDrain, uintptr(unsafe.Pointer(&t)))
+}
+
+// Config returns the line configuration of the serial port.
+func (f *File) Config() (Config, error) {
+	if err := f.Lock(); err != nil {
+		return Config{}, err
+	}
+	defer f.Unlock()
+	var t syscall.Termios
+	if err := ioctl(f.fd, ioctlGetTermios, uintptr(unsafe.Pointer(&t))); err != nil {
+		return Config{}, err
+	}
+	cfg := Config{Baud: getSpeed(&t)}
+	if cfg.Baud == 0 {
+		cfg.Baud = customSpeed(f.fd)
+	}
+	switch t.Cflag & syscall.CSIZE {
+	case syscall.CS5:
+		cfg.DataBits = 5
+	case syscall.CS6:
+		cfg.DataBits = 6
+	case syscall.CS7:
+		cfg.DataBits = 7
+	default:
+		cfg.DataBits = 8
+	}
+	if t.Cflag&syscall.PARENB != 0 {
+		odd := t.Cflag&syscall.PARODD != 0
+		switch {
+		case t.Cflag&cmspar != 0 && odd:
+			cfg.Parity = ParityMark
+		case t.Cflag&cmspar != 0:
+			cfg.Parity = ParitySpace
+		case odd:
+			cfg.Parity = ParityOdd
+		default:
+			cfg.Parity = ParityEven
+		}
+	}
+	if t.Cflag&syscall.CSTOPB != 0 {
+		cfg.StopBits = StopBits2
+		if cfg.DataBits == 5 {
+			cfg.StopBits = StopBits1Half
+		}
+	}
+	switch {
+	case t.Cflag&crtscts != 0:
+		cfg.FlowControl = FlowHardware
+	case t.Iflag&syscall.IXON != 0:
+		cfg.FlowControl = FlowXonXoff
+	}
+	return cfg, nil
+}
+
+// apply sets raw mode and the line configuration, except the
+// baudrate, in t. It fails with EINVAL if the configuration isn't
+// supported.
+func (cfg Config) apply(t *syscall.Termios) error {
+	// Raw mode, as cfmakeraw(3).
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON | syscall.IXOFF
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB | syscall.PARODD | syscall.CSTOPB | crtscts | cmspar
+	t.Cflag |= syscall.CREAD | syscall.CLOCAL
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+	switch cfg.DataBits {
+	case 5:
+		t.Cflag |= syscall.CS5
+	case 6:
+		t.Cflag |= syscall.CS6
+	case 7:
+		t.Cflag |= syscall.CS7
+	case 0, 8:
+		t.Cflag |= syscall.CS8
+	default:
+		return syscall.EINVAL
+	}
+	switch cfg.Parity {
+	case ParityNone:
+	case ParityOdd:
+		t.Cflag |= syscall.PARENB | syscall.PARODD
+	case ParityEven:
+		t.Cflag |= syscall.PARENB
+	case ParityMark, ParitySpace:
+		if cmspar == 0 {
+			return syscall.EINVAL
+		}
+		t.Cflag |= syscall.PARENB | cmspar
+		if cfg.Parity == ParityMark {
+			t.Cflag |= syscall.PARODD
+		}
+	default:
+		return syscall.EINVAL
+	}
+	switch cfg.StopBits {
+	case StopBits1:
+	case StopBits2:
+		t.Cflag |= syscall.CSTOPB
+	case StopBits1Half:
+		// CSTOPB means 1.5 stop bits with 5 data bits.
+		if cfg.DataBits != 5 {
+			return syscall.EINVAL
+		}
+		t.Cflag |= syscall.CSTOPB
+	default:
+		return syscall.EINVAL
+	}
+	switch cfg.FlowControl {
+	case FlowNone:
+	case FlowXonXoff:
+		t.Iflag |= syscall.IXON | syscall.IXOFF
+	case FlowHardware:
+		t.Cflag |= crtscts
+	default:
+		return syscall.EINVAL
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// BSD terminals take any baudrate in the termios speed fields, so
+// setSpeed only fails for invalid ones.
+
+func setCustomSpeed(fd, baud int) error {
+	return syscall.EINVAL
+}
+
+func customSpeed(fd int) int {
+	return 0
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// Termios flags for hardware flow control and mark or space (stick)
+// parity, which is not supported.
+const (
+	crtscts = 0x30000 // CCTS_OFLOW | CRTS_IFLOW
+	cmspar  = 0
+)
+
+// BSD terminals take the baudrate itself as speed.
+func setSpeed(t *syscall.Termios, baud int) error {
+	if baud <= 0 {
+		return syscall.EINVAL
+	}
+	t.Ispeed = uint64(baud)
+	t.Ospeed = uint64(baud)
+	return nil
+}
+
+func getSpeed(t *syscall.Termios) int {
+	return int(t.Ospeed)
+}
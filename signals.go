@@ -0,0 +1,87 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// SignalRecordSize is the size of the records read from a SignalFile:
+// the signal number as a 32-bit word in host byte order.
+const SignalRecordSize = 4
+
+// SignalFile is a File delivering signals, see Signals.
+type SignalFile struct {
+	*File
+}
+
+// Signals returns a SignalFile, served by the default Poller, whose
+// Read yields a SignalRecordSize record for every incoming signal in
+// sig (every signal if none is given), so signals can be handled along
+// with device I/O, deadlines included. Only the signal number is
+// provided, not the sender nor the rest of a siginfo: a real
+// signalfd(2) can't be used, since the Go runtime doesn't keep signals
+// blocked; signals are relayed from os/signal through a pipe instead,
+// and dropped if the pipe is full. Close stops the relay.
+func Signals(sig ...os.Signal) (*SignalFile, error) {
+	p, err := sysPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.SetNonblock(p[1], true); err != nil {
+		syscall.Close(p[0])
+		syscall.Close(p[1])
+		return nil, err
+	}
+	f, err := NewFile(uintptr(p[0]), "signals")
+	if err != nil {
+		syscall.Close(p[0])
+		syscall.Close(p[1])
+		return nil, err
+	}
+	ch := make(chan os.Signal, 16)
+	done := make(chan struct{})
+	f.closeF = func() error {
+		signal.Stop(ch)
+		close(done)
+		return syscall.Close(p[0])
+	}
+	go func() {
+		defer syscall.Close(p[1])
+		var rec [SignalRecordSize]byte
+		for {
+			select {
+			case s := <-ch:
+				if signo, ok := s.(syscall.Signal); ok {
+					*(*uint32)(unsafe.Pointer(&rec[0])) = uint32(signo)
+					// Records are below PIPE_BUF, so they are
+					// written whole or not at all.
+					syscall.Write(p[1], rec[:])
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	signal.Notify(ch, sig...)
+	return &SignalFile{File: f}, nil
+}
+
+// ReadSignal reads the next record and returns its signal.
+func (sf *SignalFile) ReadSignal() (syscall.Signal, error) {
+	var rec [SignalRecordSize]byte
+	if _, err := io.ReadFull(sf, rec[:]); err != nil {
+		return 0, err
+	}
+	return syscall.Signal(*(*uint32)(unsafe.Pointer(&rec[0]))), nil
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"io"
+	"syscall"
+)
+
+// splice(2) flags, missing from package syscall.
+const (
+	spliceMove     = 0x1
+	spliceNonblock = 0x2
+)
+
+// spliceChunk is the maximum number of bytes moved per splice(2) call.
+// It matches the default capacity of a pipe.
+const spliceChunk = 1 << 16
+
+// Splice moves up to n bytes (or until end of file if n < 0) from src
+// to dst without copying them through user space, using splice(2)
+// through an intermediate pipe, so any pair of Files supporting it
+// (sockets, pipes, and regular files as the source) can be used. The
+// read deadline of src and the write deadline of dst apply. It returns
+// the number of bytes written to dst and an error, if any; reaching
+// the end of file is not an error.
+func Splice(dst, src *File, n int64) (written int64, err error) {
+	src.r.m.Lock()
+	defer src.r.m.Unlock()
+	dst.w.m.Lock()
+	defer dst.w.m.Unlock()
+	s, err := newSplicer()
+	if err != nil {
+		return 0, err
+	}
+	defer s.close()
+	for n < 0 || written < n {
+		chunk := spliceChunk
+		if n >= 0 && n-written < int64(chunk) {
+			chunk = int(n - written)
+		}
+		nn, err := s.move(dst, src, chunk)
+		written += int64(nn)
+		if err != nil {
+			return written, err
+		}
+		if nn == 0 {
+			break // End of file
+		}
+	}
+	return written, nil
+}
+
+// splicer moves data between Files with splice(2), through a pipe of
+// its own.
+type splicer struct {
+	p [2]int
+}
+
+func newSplicer() (*splicer, error) {
+	s := &splicer{}
+	if err := syscall.Pipe2(s.p[:], syscall.O_CLOEXEC|syscall.O_NONBLOCK); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *splicer) close() {
+	syscall.Close(s.p[0])
+	syscall.Close(s.p[1])
+}
+
+// move waits for src to have data and moves it, up to max bytes, to
+// dst, returning zero at end of file. The caller holds the read lock of
+// src and the write lock of dst.
+func (s *splicer) move(dst, src *File, max int) (int, error) {
+	// The intermediate pipe is empty, so EAGAIN means src is not
+	// ready.
+	in, err := src.sysio(false, func(fd int) (int, error) {
+		nn, err := syscall.Splice(fd, nil, s.p[1], nil, max, spliceMove|spliceNonblock)
+		return int(nn), err
+	})
+	if err != nil {
+		return 0, src.opError("splice", err)
+	}
+	written := 0
+	for written < in {
+		out, err := dst.sysio(true, func(fd int) (int, error) {
+			nn, err := syscall.Splice(s.p[0], nil, fd, nil, in-written, spliceMove|spliceNonblock)
+			return int(nn), err
+		})
+		if err == nil && out == 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		written += out
+		if err != nil {
+			// What's left in the pipe is lost, it can't be reused.
+			return written, dst.opError("splice", err)
+		}
+	}
+	return written, nil
+}
+
+// spliceRelay returns a function moving the data src has ready to dst,
+// with splice(2), for the Proxy. ok is false if they don't support it.
+func spliceRelay(dst, src *File) (relay func() (int, error), stop func(), ok bool) {
+	if !spliceable(src.fd) || !spliceable(dst.fd) {
+		return nil, nil, false
+	}
+	s, err := newSplicer()
+	if err != nil {
+		return nil, nil, false
+	}
+	relay = func() (int, error) {
+		src.r.m.Lock()
+		defer src.r.m.Unlock()
+		dst.w.m.Lock()
+		defer dst.w.m.Unlock()
+		return s.move(dst, src, spliceChunk)
+	}
+	return relay, s.close, true
+}
+
+// fileType returns the file type bits (S_IFMT) of fd, zero on error.
+func fileType(fd int) uint32 {
+	var st syscall.Stat_t
+	if err := syscall.Fstat(fd, &st); err != nil {
+		return 0
+	}
+	return st.Mode & syscall.S_IFMT
+}
+
+// spliceable reports whether fd is a pipe or a socket.
+func spliceable(fd int) bool {
+	t := fileType(fd)
+	return t == syscall.S_IFIFO || t == syscall.S_IFSOCK
+}
+
+// spliceFrom implements ReadFrom with Splice when both r (a File, a
+// Conn or an io.LimitedReader wrapping them) and f are pipes or
+// sockets. handled is false if Splice can't be used.
+func (f *File) spliceFrom(r io.Reader) (written int64, handled bool, err error) {
+	n := int64(-1)
+	lr, ok := r.(*io.LimitedReader)
+	if ok {
+		n, r = lr.N, lr.R
+		if n <= 0 {
+			return 0, true, nil
+		}
+	}
+	var src *File
+	switch x := r.(type) {
+	case *File:
+		src = x
+	case *Conn:
+		src = x.File
+	default:
+		return 0, false, nil
+	}
+	if !spliceable(src.fd) || !spliceable(f.fd) {
+		return 0, false, nil
+	}
+	written, err = Splice(f, src, n)
+	if lr != nil {
+		lr.N -= written
+	}
+	return written, true, err
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "io"
+
+func (f *File) spliceFrom(r io.Reader) (written int64, handled bool, err error) {
+	return 0, false, nil
+}
+
+func (f *File) sendfileFrom(r io.Reader) (written int64, handled bool, err error) {
+	return 0, false, nil
+}
+
+func spliceRelay(dst, src *File) (relay func() (int, error), stop func(), ok bool) {
+	return nil, nil, false
+}
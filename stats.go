@@ -0,0 +1,205 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// PollerStats are the run statistics of the event loop of a Poller.
+// They cover the period from Started to Time: since the loop was
+// started for the snapshots returned by Stats, or between two
+// snapshots for the result of Diff.
+type PollerStats struct {
+	Started      time.Time     // Start of the period
+	Time         time.Time     // End of the period (time of the snapshot)
+	Waits        uint64        // Calls to the wait system call (epoll_wait, kevent, select or poll)
+	Events       uint64        // Ready descriptors returned by the wait calls
+	Wakeups      uint64        // Wake-ups requested through the wakeup pipe
+	WakeupReads  uint64        // Wake-ups handled by the loop (several requests may be coalesced)
+	WaitTime     time.Duration // Time spent in the wait system call
+	DispatchTime time.Duration // Time spent dispatching events to Files
+	// Events for descriptors no longer served by Poller, usually
+	// closed behind the back of their File (see OnDropped).
+	Dropped uint64
+	// Failed wait calls, including those interrupted (EINTR), and
+	// the last error, nil if none.
+	WaitErrors    uint64
+	LastWaitError error
+}
+
+// EventsPerSec returns the average rate of events in the period.
+func (s PollerStats) EventsPerSec() float64 {
+	d := s.Time.Sub(s.Started).Seconds()
+	if d <= 0 {
+		return 0
+	}
+	return float64(s.Events) / d
+}
+
+// AvgBatch returns the average number of events returned by a wait
+// call.
+func (s PollerStats) AvgBatch() float64 {
+	if s.Waits == 0 {
+		return 0
+	}
+	return float64(s.Events) / float64(s.Waits)
+}
+
+// Diff returns the statistics of the period between the snapshots
+// prev and s, so sampling agents can compute rates.
+func (s PollerStats) Diff(prev PollerStats) PollerStats {
+	d := PollerStats{
+		Started:      prev.Time,
+		Time:         s.Time,
+		Waits:        s.Waits - prev.Waits,
+		Events:       s.Events - prev.Events,
+		Wakeups:      s.Wakeups - prev.Wakeups,
+		WakeupReads:  s.WakeupReads - prev.WakeupReads,
+		WaitTime:     s.WaitTime - prev.WaitTime,
+		DispatchTime: s.DispatchTime - prev.DispatchTime,
+		Dropped:      s.Dropped - prev.Dropped,
+		WaitErrors:   s.WaitErrors - prev.WaitErrors,
+	}
+	if d.WaitErrors > 0 {
+		d.LastWaitError = s.LastWaitError
+	}
+	return d
+}
+
+// loopStats are the counters behind PollerStats. Atomic access. The
+// loop goroutine, the only writer of the counters but wakeups,
+// increments seq before and after every update (seqlock), so readers
+// get consistent snapshots without locking the loop. wakeups is
+// updated by any goroutine, outside of the seqlock.
+type loopStats struct {
+	seq         uint64
+	waits       uint64
+	events      uint64
+	wakeups     uint64
+	wakeupReads uint64
+	waitNs      int64
+	dispatchNs  int64
+	dropped     uint64
+	waitErrors  uint64
+	lastErr     atomic.Value // errorBox
+}
+
+// errorBox wraps the errors stored in an atomic.Value, which requires
+// a consistent type.
+type errorBox struct {
+	err error
+}
+
+// Stats returns a snapshot of the run statistics of the event loop of
+// Poller. The counters updated by the loop are mutually consistent;
+// Wakeups, counted by the goroutines requesting them, may be ahead.
+func (p *Poller) Stats() PollerStats {
+	for {
+		seq := atomic.LoadUint64(&p.stats.seq)
+		if seq&1 != 0 {
+			// Update in progress.
+			runtime.Gosched()
+			continue
+		}
+		s := PollerStats{
+			Started:      p.started,
+			Time:         time.Now(),
+			Waits:        atomic.LoadUint64(&p.stats.waits),
+			Events:       atomic.LoadUint64(&p.stats.events),
+			Wakeups:      atomic.LoadUint64(&p.stats.wakeups),
+			WakeupReads:  atomic.LoadUint64(&p.stats.wakeupReads),
+			WaitTime:     time.Duration(atomic.LoadInt64(&p.stats.waitNs)),
+			DispatchTime: time.Duration(atomic.LoadInt64(&p.stats.dispatchNs)),
+			Dropped:      atomic.LoadUint64(&p.stats.dropped),
+			WaitErrors:   atomic.LoadUint64(&p.stats.waitErrors),
+		}
+		if b, ok := p.stats.lastErr.Load().(errorBox); ok {
+			s.LastWaitError = b.err
+		}
+		if atomic.LoadUint64(&p.stats.seq) == seq {
+			return s
+		}
+	}
+}
+
+// Stats returns the run statistics of the default Poller.
+func Stats() (PollerStats, error) {
+	p, err := defaultPoller()
+	if err != nil {
+		return PollerStats{}, err
+	}
+	return p.Stats(), nil
+}
+
+// waited records a wait call started at t0 which returned n events
+// and returns the time dispatching starts.
+func (p *Poller) waited(t0 time.Time, n int) time.Time {
+	t1 := time.Now()
+	p.evTime = t1
+	atomic.AddUint64(&p.stats.seq, 1)
+	atomic.AddUint64(&p.stats.waits, 1)
+	if n > 0 {
+		atomic.AddUint64(&p.stats.events, uint64(n))
+	}
+	atomic.AddInt64(&p.stats.waitNs, int64(t1.Sub(t0)))
+	atomic.AddUint64(&p.stats.seq, 1)
+	return t1
+}
+
+// dispatched records the time spent dispatching events since t1.
+func (p *Poller) dispatched(t1 time.Time) {
+	d := time.Since(t1)
+	atomic.AddUint64(&p.stats.seq, 1)
+	atomic.AddInt64(&p.stats.dispatchNs, int64(d))
+	atomic.AddUint64(&p.stats.seq, 1)
+}
+
+// wakeupRead records a wake-up handled by the loop.
+func (p *Poller) wakeupRead() {
+	atomic.AddUint64(&p.stats.seq, 1)
+	atomic.AddUint64(&p.stats.wakeupReads, 1)
+	atomic.AddUint64(&p.stats.seq, 1)
+}
+
+// wakeupSent records a wake-up request.
+func (p *Poller) wakeupSent() {
+	atomic.AddUint64(&p.stats.wakeups, 1)
+}
+
+// waitFailed records an error of the wait call.
+func (p *Poller) waitFailed(err error) {
+	atomic.AddUint64(&p.stats.seq, 1)
+	atomic.AddUint64(&p.stats.waitErrors, 1)
+	p.stats.lastErr.Store(errorBox{err})
+	atomic.AddUint64(&p.stats.seq, 1)
+}
+
+// dropped records an event for fd, which Poller doesn't serve.
+func (p *Poller) dropped(fd int, events Events) {
+	atomic.AddUint64(&p.stats.seq, 1)
+	atomic.AddUint64(&p.stats.dropped, 1)
+	atomic.AddUint64(&p.stats.seq, 1)
+	p.fdmLock.Lock()
+	fn := p.onDropped
+	p.fdmLock.Unlock()
+	if fn != nil {
+		fn(fd, events)
+	}
+}
+
+// OnDropped sets fn to be called for every event dropped because its
+// descriptor isn't served by Poller (see PollerStats.Dropped), e.g. to
+// log lifecycle bugs like descriptors closed behind the back of their
+// File. fn is called by the event loop goroutine and must not block. A
+// nil fn removes it.
+func (p *Poller) OnDropped(fn func(fd int, events Events)) {
+	p.fdmLock.Lock()
+	p.onDropped = fn
+	p.fdmLock.Unlock()
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// ReadStruct reads binary.Size(v) bytes from File and decodes them into
+// v, a pointer to fixed-size data (e.g. a struct of sized integers and
+// arrays, as the registers of a device), in byte order order, like
+// binary.Read. The read deadline applies to the whole operation.
+func (f *File) ReadStruct(order binary.ByteOrder, v interface{}) error {
+	return binary.Read(f, order, v)
+}
+
+// WriteStruct encodes v, fixed-size data, in byte order order and
+// writes it with a single Write, like binary.Write. The write deadline
+// applies.
+func (f *File) WriteStruct(order binary.ByteOrder, v interface{}) error {
+	return binary.Write(f, order, v)
+}
+
+// ReadStruct reads the next frame and decodes it into v, a pointer to
+// fixed-size data, in byte order order. The frame (without checksum)
+// must be exactly binary.Size(v) bytes long.
+func (fr *FrameReader) ReadStruct(order binary.ByteOrder, v interface{}) error {
+	frame, err := fr.ReadFrame()
+	if err != nil {
+		return err
+	}
+	if size := binary.Size(v); len(frame) != size {
+		return fmt.Errorf("poll: frame of %d bytes, want %d", len(frame), size)
+	}
+	return binary.Read(bytes.NewReader(frame), order, v)
+}
+
+// WriteStruct encodes v, fixed-size data, in byte order order and
+// writes it as a frame.
+func (fw *FrameWriter) WriteStruct(order binary.ByteOrder, v interface{}) error {
+	size := binary.Size(v)
+	if size < 0 {
+		return fmt.Errorf("poll: WriteStruct of invalid type %T", v)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	if err := binary.Write(buf, order, v); err != nil {
+		return err
+	}
+	return fw.WriteFrame(buf.Bytes())
+}
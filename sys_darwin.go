@@ -0,0 +1,34 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// sysAccept accepts a connection as a close-on-exec socket. darwin has
+// no accept4(2).
+func sysAccept(fd int) (int, syscall.Sockaddr, error) {
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+	nfd, sa, err := syscall.Accept(fd)
+	if err != nil {
+		return -1, nil, err
+	}
+	syscall.CloseOnExec(nfd)
+	return nfd, sa, nil
+}
+
+// sysPipe creates a close-on-exec pipe. darwin has no pipe2(2).
+func sysPipe() ([2]int, error) {
+	var p [2]int
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+	if err := syscall.Pipe(p[:]); err != nil {
+		return p, err
+	}
+	syscall.CloseOnExec(p[0])
+	syscall.CloseOnExec(p[1])
+	return p, nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// errAgain is the error of a system call which would block. Plan 9
+// has no non-blocking descriptors, so it's only returned by the
+// emulation of the event loop (see evloop_plan9.go).
+var errAgain = errors.New("resource temporarily unavailable")
+
+// errPipe is the error of a write to a File shut down for writing.
+var errPipe = errors.New("write on closed pipe")
+
+// setNonblock does nothing, Plan 9 descriptors are always blocking.
+func setNonblock(fd int, nonblocking bool) error {
+	return nil
+}
+
+// sysOpen opens the named path close-on-exec. Plan 9 has no O_CREATE
+// flag but a create(2) call, used as os.OpenFile does. O_APPEND is
+// emulated seeking to the end of the file once, after opening it.
+func sysOpen(name string, flags int, perm os.FileMode) (int, error) {
+	create := flags&syscall.O_CREAT != 0
+	appnd := flags&syscall.O_APPEND != 0
+	flags = flags&^(syscall.O_CREAT|syscall.O_APPEND) | syscall.O_CLOEXEC
+	var fd int
+	var err error
+	if create && flags&(syscall.O_TRUNC|syscall.O_EXCL) != 0 {
+		fd, err = syscall.Create(name, flags, uint32(perm.Perm()))
+	} else {
+		fd, err = syscall.Open(name, flags)
+		if create && os.IsNotExist(err) {
+			fd, err = syscall.Create(name, flags, uint32(perm.Perm()))
+		}
+	}
+	if err != nil {
+		return -1, err
+	}
+	if appnd {
+		if _, err := syscall.Seek(fd, 0, io.SeekEnd); err != nil {
+			syscall.Close(fd)
+			return -1, err
+		}
+	}
+	return fd, nil
+}
+
+// sockError returns nil, Plan 9 reports network errors through the
+// reads and writes of the data file.
+func sockError(fd int) error {
+	return nil
+}
+
+// Driver is not supported on Plan 9.
+func (f *File) Driver() (string, error) {
+	return "", syscall.EPLAN9
+}
+
+// readyChan and backpressure support ReadyC and Backpressure, which
+// are not available on Plan 9.
+type readyChan struct{}
+
+type backpressure struct{}
@@ -0,0 +1,117 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"os"
+	"syscall"
+)
+
+// errAgain is the error of a system call which would block.
+const errAgain = syscall.EAGAIN
+
+// errPipe is the error of a write to a File shut down for writing.
+const errPipe = syscall.EPIPE
+
+// sysRead and sysWrite do the reads and writes of Files.
+func sysRead(fd int, p []byte) (int, error) {
+	return syscall.Read(fd, p)
+}
+
+func sysWrite(fd int, p []byte) (int, error) {
+	return syscall.Write(fd, p)
+}
+
+// setNonblock sets the non-blocking mode of fd.
+func setNonblock(fd int, nonblocking bool) error {
+	return syscall.SetNonblock(fd, nonblocking)
+}
+
+// sysOpen opens the named path non-blocking and close-on-exec.
+func sysOpen(name string, flags int, perm os.FileMode) (int, error) {
+	return syscall.Open(name, flags|syscall.O_CLOEXEC|syscall.O_NONBLOCK, syscallMode(perm))
+}
+
+// syscallMode converts perm to open(2) mode bits.
+func syscallMode(perm os.FileMode) uint32 {
+	m := uint32(perm.Perm())
+	if perm&os.ModeSetuid != 0 {
+		m |= syscall.S_ISUID
+	}
+	if perm&os.ModeSetgid != 0 {
+		m |= syscall.S_ISGID
+	}
+	if perm&os.ModeSticky != 0 {
+		m |= syscall.S_ISVTX
+	}
+	return m
+}
+
+// sysSocket creates a close-on-exec socket.
+func sysSocket(domain, typ, proto int) (int, error) {
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+	fd, err := syscall.Socket(domain, typ, proto)
+	if err != nil {
+		return -1, err
+	}
+	syscall.CloseOnExec(fd)
+	return fd, nil
+}
+
+// sysSocketpair creates a pair of connected close-on-exec sockets.
+func sysSocketpair(domain, typ, proto int) ([2]int, error) {
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+	fds, err := syscall.Socketpair(domain, typ, proto)
+	if err != nil {
+		return fds, err
+	}
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+	return fds, nil
+}
+
+// Pipe returns a connected pair of Files, served by the default
+// Poller: reads from r return bytes written to w. The pipe is created
+// non-blocking and close-on-exec.
+func Pipe() (r *File, w *File, err error) {
+	p, err := sysPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	return newFilePair(p, "|0", "|1")
+}
+
+// newFilePair wraps both fds as Files served by the default Poller,
+// closing them on failure.
+func newFilePair(fds [2]int, name0, name1 string) (*File, *File, error) {
+	f0, err := NewFile(uintptr(fds[0]), name0)
+	if err != nil {
+		syscall.Close(fds[0])
+		syscall.Close(fds[1])
+		return nil, nil, err
+	}
+	f1, err := NewFile(uintptr(fds[1]), name1)
+	if err != nil {
+		f0.Close()
+		syscall.Close(fds[1])
+		return nil, nil, err
+	}
+	return f0, f1, nil
+}
+
+// sockError returns the pending error (SO_ERROR) of socket fd, if any.
+func sockError(fd int) error {
+	n, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_ERROR)
+	if err != nil || n == 0 {
+		return nil
+	}
+	return syscall.Errno(n)
+}
@@ -0,0 +1,159 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// sdListenFdsStart is the first file descriptor passed by systemd.
+const sdListenFdsStart = 3
+
+// FilesFromSystemd returns the file descriptors passed by systemd
+// socket activation (LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES
+// environment variables) as Files registered with the default Poller.
+// Files are named after LISTEN_FDNAMES, or "LISTEN_FD_<n>" if no name
+// was given. It returns no Files if the variables are not set or are
+// meant for another process. If unsetEnv is true the variables are
+// removed, so they are not inherited by child processes.
+func FilesFromSystemd(unsetEnv bool) ([]*File, error) {
+	if unsetEnv {
+		defer func() {
+			os.Unsetenv("LISTEN_PID")
+			os.Unsetenv("LISTEN_FDS")
+			os.Unsetenv("LISTEN_FDNAMES")
+		}()
+	}
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	files := make([]*File, 0, nfds)
+	for i := 0; i < nfds; i++ {
+		fd := sdListenFdsStart + i
+		syscall.CloseOnExec(fd)
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f, err := NewFile(uintptr(fd), name)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// sdNotifyTimeout bounds the time spent sending a notification.
+const sdNotifyTimeout = time.Second
+
+// SdNotify sends state (e.g. "READY=1", "STOPPING=1", "STATUS=...")
+// to the systemd notification socket named by NOTIFY_SOCKET. It
+// returns false and no error if NOTIFY_SOCKET is not set.
+func SdNotify(state string) (bool, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return false, nil
+	}
+	fd, err := sysSocket(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return false, err
+	}
+	// A leading '@' selects the abstract namespace.
+	if err = syscall.Connect(fd, &syscall.SockaddrUnix{Name: path}); err != nil {
+		syscall.Close(fd)
+		return false, err
+	}
+	f, err := NewFile(uintptr(fd), path)
+	if err != nil {
+		syscall.Close(fd)
+		return false, err
+	}
+	defer f.Close()
+	f.SetWriteDeadline(time.Now().Add(sdNotifyTimeout))
+	if _, err = f.WritePacket([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SdWatchdogInterval returns the watchdog interval configured by
+// systemd for this process (WATCHDOG_USEC and WATCHDOG_PID environment
+// variables), or zero if the watchdog is not enabled.
+func SdWatchdogInterval() (time.Duration, error) {
+	s := os.Getenv("WATCHDOG_USEC")
+	if s == "" {
+		return 0, nil
+	}
+	if p := os.Getenv("WATCHDOG_PID"); p != "" {
+		pid, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, err
+		}
+		if pid != os.Getpid() {
+			return 0, nil
+		}
+	}
+	usec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if usec <= 0 {
+		return 0, nil
+	}
+	return time.Duration(usec) * time.Microsecond, nil
+}
+
+// StartSdWatchdog sends "WATCHDOG=1" to systemd at half the watchdog
+// interval until stop is called. If the watchdog is not enabled it
+// does nothing. Notification errors are passed to errf, if not nil.
+func StartSdWatchdog(errf func(error)) (stop func(), err error) {
+	interval, err := SdWatchdogInterval()
+	if err != nil {
+		return nil, err
+	}
+	if interval == 0 {
+		return func() {}, nil
+	}
+	t := time.NewTicker(interval / 2)
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-t.C:
+				if _, err := SdNotify("WATCHDOG=1"); err != nil && errf != nil {
+					errf(err)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.Stop()
+			close(quit)
+		})
+	}, nil
+}
@@ -0,0 +1,49 @@
+//go:build !ppc64 && !ppc64le
+// +build !ppc64,!ppc64le
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// bother (BOTHER) selects the baudrate in the speed fields of termios2
+// instead of a Bxxx code.
+const bother = 0x1000
+
+// setCustomSpeed sets an arbitrary baudrate with TCSETSW2, once
+// pending output has been transmitted.
+func setCustomSpeed(fd, baud int) error {
+	if baud <= 0 {
+		return syscall.EINVAL
+	}
+	var t termios2
+	if err := ioctl(fd, ioctlGetTermios2, uintptr(unsafe.Pointer(&t))); err != nil {
+		return err
+	}
+	// Clear the input speed code (CIBAUD) too, so both directions use
+	// the same rate.
+	t.Cflag &^= speedMask | speedMask<<16
+	t.Cflag |= bother | bother<<16
+	t.Ispeed = uint32(baud)
+	t.Ospeed = uint32(baud)
+	return ioctl(fd, ioctlSetTermios2Drain, uintptr(unsafe.Pointer(&t)))
+}
+
+// customSpeed returns the baudrate set by setCustomSpeed, or zero.
+func customSpeed(fd int) int {
+	var t termios2
+	if err := ioctl(fd, ioctlGetTermios2, uintptr(unsafe.Pointer(&t))); err != nil {
+		return 0
+	}
+	if t.Cflag&speedMask != bother {
+		return 0
+	}
+	return int(t.Ospeed)
+}
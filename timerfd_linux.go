@@ -0,0 +1,148 @@
+//go:build linux && !select && !poll
+// +build linux,!select,!poll
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"container/heap"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// timerQueue multiplexes deadline timers on a timerfd(2) served by the
+// event loop. The timerfd is armed for the earliest timer.
+type timerQueue struct {
+	fd   int
+	m    sync.Mutex
+	h    timerHeap // Guarded by m
+	base time.Time // Monotonic time origin of the timers
+}
+
+// queuedTimer is a timer of a timerQueue. It implements deadlineTimer.
+type queuedTimer struct {
+	q     *timerQueue
+	when  time.Duration // Since q.base
+	fn    func()
+	index int // In q.h, -1 if not queued
+}
+
+type timerHeap []*queuedTimer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].when < h[j].when }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*queuedTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	t.index = -1
+	return t
+}
+
+// clockMonotonic is CLOCK_MONOTONIC, not exported by package syscall.
+const clockMonotonic = 1
+
+// itimerspec is the struct itimerspec of timerfd_settime(2).
+type itimerspec struct {
+	interval syscall.Timespec
+	value    syscall.Timespec
+}
+
+func newTimerQueue() (*timerQueue, error) {
+	fd, _, e := syscall.Syscall(syscall.SYS_TIMERFD_CREATE, clockMonotonic,
+		uintptr(syscall.O_NONBLOCK|syscall.O_CLOEXEC), 0)
+	if e != 0 {
+		return nil, e
+	}
+	return &timerQueue{fd: int(fd), base: time.Now()}, nil
+}
+
+// arm sets the timerfd to expire with the earliest timer, or disarms
+// it. Called with q.m held.
+func (q *timerQueue) arm() {
+	var its itimerspec
+	if len(q.h) > 0 {
+		d := q.h[0].when - time.Since(q.base)
+		if d <= 0 {
+			d = 1 // Zero would disarm.
+		}
+		its.value = syscall.NsecToTimespec(int64(d))
+	}
+	syscall.Syscall6(syscall.SYS_TIMERFD_SETTIME, uintptr(q.fd), 0,
+		uintptr(unsafe.Pointer(&its)), 0, 0, 0)
+}
+
+// expire runs the expired timers. Called by the event loop when the
+// timerfd is readable.
+func (q *timerQueue) expire() {
+	var buf [8]byte
+	syscall.Read(q.fd, buf[:])
+	var fns []func()
+	q.m.Lock()
+	now := time.Since(q.base)
+	for len(q.h) > 0 && q.h[0].when <= now {
+		t := heap.Pop(&q.h).(*queuedTimer)
+		fns = append(fns, t.fn)
+	}
+	q.arm()
+	q.m.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+func (q *timerQueue) close() error {
+	return syscall.Close(q.fd)
+}
+
+// Reset changes the timer to expire after d. It returns true if the
+// timer was active.
+func (t *queuedTimer) Reset(d time.Duration) bool {
+	q := t.q
+	q.m.Lock()
+	defer q.m.Unlock()
+	active := t.index >= 0
+	t.when = time.Since(q.base) + d
+	if active {
+		heap.Fix(&q.h, t.index)
+	} else {
+		heap.Push(&q.h, t)
+	}
+	if t.index == 0 {
+		q.arm()
+	}
+	return active
+}
+
+// Stop prevents the timer from firing. It returns true if the timer
+// was active.
+func (t *queuedTimer) Stop() bool {
+	q := t.q
+	q.m.Lock()
+	defer q.m.Unlock()
+	if t.index < 0 {
+		return false
+	}
+	// The timerfd may expire for nothing; expire copes with it.
+	heap.Remove(&q.h, t.index)
+	return true
+}
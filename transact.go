@@ -0,0 +1,142 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"bytes"
+	"context"
+	"syscall"
+	"time"
+)
+
+// TransactOptions configure a Transact exchange. The zero value writes
+// the request and returns whatever the first read gets, waiting as
+// long as it takes.
+type TransactOptions struct {
+	// Timeout covers the whole exchange, including the wait for the
+	// read lease. Zero means no timeout.
+	Timeout time.Duration
+	// FlushInput discards the input pending before writing the
+	// request, e.g. a late response to a previous request.
+	FlushInput bool
+	// Terminator ends the response (e.g. "\r\n"), which includes it.
+	// Bytes received after it are discarded.
+	Terminator []byte
+	// Length is the size of fixed length responses, used if
+	// Terminator is empty.
+	Length int
+}
+
+// Transact writes the request req and reads the response into resp,
+// returning its size, under a read lease (see AcquireReader) so the
+// exchanges of concurrent callers don't get mixed up. The response
+// ends with opts.Terminator, or after opts.Length bytes; if resp fills
+// up before, Transact fails with ErrTruncated. It fails with
+// ErrTimeout if opts.Timeout expires first; the read and write
+// deadlines of File apply too. opts may be nil.
+func (f *File) Transact(req, resp []byte, opts *TransactOptions) (int, error) {
+	return f.TransactContext(context.Background(), req, resp, opts)
+}
+
+// TransactContext is like Transact, but it gives up with ctx.Err()
+// once ctx is done.
+func (f *File) TransactContext(ctx context.Context, req, resp []byte, opts *TransactOptions) (int, error) {
+	if opts == nil {
+		opts = &TransactOptions{}
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	n, err := f.transact(ctx, req, resp, opts)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded && opts.Timeout > 0 {
+			err = ErrTimeout
+		} else if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if _, ok := err.(*OpError); !ok {
+			err = f.opError("transact", err)
+		}
+	}
+	return n, err
+}
+
+func (f *File) transact(ctx context.Context, req, resp []byte, opts *TransactOptions) (int, error) {
+	l, err := f.AcquireReader(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer l.Release()
+	if opts.FlushInput {
+		if err := f.discardInput(); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := f.WriteContext(ctx, req); err != nil {
+		return 0, err
+	}
+	n := 0
+	for {
+		if n == len(resp) {
+			return n, ErrTruncated
+		}
+		b := resp[n:]
+		if len(opts.Terminator) == 0 && opts.Length > 0 && len(b) > opts.Length-n {
+			b = b[:opts.Length-n]
+		}
+		nn, err := l.Read(b)
+		if err != nil {
+			return n, err
+		}
+		// Look for the terminator in the new data, and the bytes of it
+		// which may have been received before.
+		start := n - len(opts.Terminator) + 1
+		if start < 0 {
+			start = 0
+		}
+		n += nn
+		switch {
+		case len(opts.Terminator) > 0:
+			if i := bytes.Index(resp[start:n], opts.Terminator); i >= 0 {
+				return start + i + len(opts.Terminator), nil
+			}
+		case opts.Length > 0:
+			if n == opts.Length {
+				return n, nil
+			}
+		default:
+			return n, nil
+		}
+	}
+}
+
+// discardInput discards the data pending to be read: the input queue of
+// a tty, or whatever can be read right away from other Files.
+func (f *File) discardInput() error {
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	err := tcflush(f.fd, syscall.TCIFLUSH)
+	if err != syscall.ENOTTY {
+		return err
+	}
+	var buf [512]byte
+	for {
+		n, err := syscall.Read(f.fd, buf[:])
+		if err == syscall.EAGAIN || n == 0 && err == nil {
+			return nil
+		}
+		if err != nil && err != syscall.EINTR {
+			return err
+		}
+	}
+}
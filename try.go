@@ -0,0 +1,49 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "io"
+
+// TryRead is like Read, but it never waits: it makes a single read(2)
+// and fails with ErrWouldBlock (errors.Is(err, ErrWouldBlock)) if File
+// isn't readable, or if another read is in progress, for polling style
+// consumers like game loops and schedulers.
+func (f *File) TryRead(p []byte) (n int, err error) {
+	n, err = f.tryIO(false, p)
+	if err == nil && n == 0 && len(p) != 0 {
+		err = io.EOF
+	}
+	if err != nil && err != io.EOF {
+		err = f.opError("read", err)
+	}
+	return n, err
+}
+
+// TryWrite is like TryRead, for writing. It makes a single write(2),
+// which may write only part of p.
+func (f *File) TryWrite(p []byte) (n int, err error) {
+	n, err = f.tryIO(true, p)
+	if err != nil {
+		err = f.opError("write", err)
+	}
+	return n, err
+}
+
+func (f *File) tryIO(write bool, p []byte) (int, error) {
+	fdc := &f.r
+	rw := sysRead
+	if write {
+		fdc = &f.w
+		rw = sysWrite
+	}
+	if !fdc.m.tryLock() {
+		return 0, ErrWouldBlock
+	}
+	defer fdc.m.Unlock()
+	return f.sysioWait(write, false, func(fd int) (int, error) {
+		return rw(fd, p)
+	})
+}
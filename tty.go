@@ -0,0 +1,204 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+func ioctl(fd int, req uint, arg uintptr) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(req), arg)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+func fcntl(fd int, cmd int, arg int) (int, error) {
+	r, _, e := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), uintptr(cmd), uintptr(arg))
+	if e != 0 {
+		return 0, e
+	}
+	return int(r), nil
+}
+
+// SetExclusive puts the tty in exclusive mode (TIOCEXCL), so further
+// open(2) calls on the device fail with EBUSY, or clears it (TIOCNXCL).
+// Exclusive mode doesn't stop processes running as root; use LockFile
+// as well if that matters.
+func (f *File) SetExclusive(excl bool) error {
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	req := uint(syscall.TIOCNXCL)
+	if excl {
+		req = syscall.TIOCEXCL
+	}
+	return ioctl(f.fd, req, 0)
+}
+
+// LockFile creates a UUCP style lock file (LCK..<device>) for File in
+// dir (DefaultLockDir if empty). Stale lock files, left by processes no
+// longer running, are removed. If the device is locked by another live
+// process ErrLocked is returned. The lock file is removed on Close.
+func (f *File) LockFile(dir string) error {
+	if dir == "" {
+		dir = DefaultLockDir
+	}
+	path := filepath.Join(dir, "LCK.."+filepath.Base(f.name))
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	if f.lockF != "" {
+		return nil
+	}
+	for {
+		lf, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(lf, "%10d\n", os.Getpid())
+			if cerr := lf.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return err
+			}
+			f.lockF = path
+			return nil
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		if lockOwnerAlive(path) {
+			return ErrLocked
+		}
+		// Stale lock file.
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+}
+
+// lockOwnerAlive reports whether the process which owns the lock file
+// at path is still running. Unreadable lock files are assumed alive.
+func lockOwnerAlive(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return !os.IsNotExist(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	err = syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// Termios returns the current terminal attributes of File.
+func (f *File) Termios() (*syscall.Termios, error) {
+	if err := f.Lock(); err != nil {
+		return nil, err
+	}
+	defer f.Unlock()
+	t := &syscall.Termios{}
+	if err := ioctl(f.fd, ioctlGetTermios, uintptr(unsafe.Pointer(t))); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reconfigure changes the terminal attributes (baudrate, parity, flow
+// control, etc.) of a live File without reopening it. It waits for
+// in-flight Reads and Writes to finish (subject to their deadlines; a
+// Read waiting for data with no deadline holds Reconfigure off until
+// data arrives) and then, with both directions locked, calls fn with
+// the current attributes. If fn returns nil the modified attributes
+// are applied once all pending output has been transmitted (TCSETSW).
+// Reads and Writes resume with the new settings as soon as
+// Reconfigure returns.
+func (f *File) Reconfigure(fn func(t *syscall.Termios) error) error {
+	f.r.m.Lock()
+	defer f.r.m.Unlock()
+	f.w.m.Lock()
+	defer f.w.m.Unlock()
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	var t syscall.Termios
+	if err := ioctl(f.fd, ioctlGetTermios, uintptr(unsafe.Pointer(&t))); err != nil {
+		return err
+	}
+	if err := fn(&t); err != nil {
+		return err
+	}
+	return ioctl(f.fd, ioctlSetTermiosDrain, uintptr(unsafe.Pointer(&t)))
+}
+
+// FlushInput discards the data received by the tty but not yet read.
+func (f *File) FlushInput() error {
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	return tcflush(f.fd, syscall.TCIFLUSH)
+}
+
+// FlushOutput discards the data written to the tty but not yet
+// transmitted.
+func (f *File) FlushOutput() error {
+	if err := f.Lock(); err != nil {
+		return err
+	}
+	defer f.Unlock()
+	return tcflush(f.fd, syscall.TCOFLUSH)
+}
+
+// DrainPoll is how often Drain checks the output queue of the tty.
+var DrainPoll = 10 * time.Millisecond
+
+// Drain waits until the data written to the tty has been transmitted.
+// Unlike tcdrain(3) it can be aborted: it fails with ErrTimeout when
+// deadline (if not zero) or the write deadline of File expire, and
+// with ErrClosed if File is closed. Writes are held off until Drain
+// returns.
+func (f *File) Drain(deadline time.Time) error {
+	f.w.m.Lock()
+	defer f.w.m.Unlock()
+	for {
+		if err := f.Lock(); err != nil {
+			return err
+		}
+		n, err := outq(f.fd)
+		if err == nil && n == 0 {
+			// Wait for the bytes in the hardware FIFO, if any.
+			err = tcdrain(f.fd)
+		}
+		f.Unlock()
+		if err != nil || n == 0 {
+			return err
+		}
+		f.w.cond.L.Lock()
+		timeout := f.w.timeout
+		f.w.cond.L.Unlock()
+		if timeout || !deadline.IsZero() && !time.Now().Before(deadline) {
+			return ErrTimeout
+		}
+		time.Sleep(DrainPoll)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	ioctlGetTermios      = syscall.TIOCGETA
+	ioctlSetTermios      = syscall.TIOCSETA
+	ioctlSetTermiosDrain = syscall.TIOCSETAW
+)
+
+// tcflush discards the data in queue (TCIFLUSH or TCOFLUSH, which
+// match the FREAD and FWRITE flags of TIOCFLUSH).
+func tcflush(fd, queue int) error {
+	q := int32(queue)
+	return ioctl(fd, syscall.TIOCFLUSH, uintptr(unsafe.Pointer(&q)))
+}
+
+// tcdrain waits until the output queued has been transmitted.
+func tcdrain(fd int) error {
+	return ioctl(fd, syscall.TIOCDRAIN, 0)
+}
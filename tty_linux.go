@@ -0,0 +1,27 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// TCSETSW ("drain output, then set") follows TCSETS on every
+// architecture, but only the latter is exported by package syscall.
+const (
+	ioctlGetTermios      = syscall.TCGETS
+	ioctlSetTermios      = syscall.TCSETS
+	ioctlSetTermiosDrain = syscall.TCSETS + 1
+)
+
+// tcflush discards the data in queue (TCIFLUSH or TCOFLUSH).
+func tcflush(fd, queue int) error {
+	return ioctl(fd, ioctlFlush, uintptr(queue))
+}
+
+// tcdrain waits until the output queued has been transmitted. TCSBRK
+// with a non-zero argument sends no break.
+func tcdrain(fd int) error {
+	return ioctl(fd, ioctlBreak, 1)
+}
@@ -0,0 +1,40 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le && !ppc64 && !ppc64le
+// +build linux,!mips,!mipsle,!mips64,!mips64le,!ppc64,!ppc64le
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+// termios2 is the Linux struct termios2, which carries the baudrate
+// in the speed fields.
+type termios2 struct {
+	Iflag  uint32
+	Oflag  uint32
+	Cflag  uint32
+	Lflag  uint32
+	Line   uint8
+	Cc     [19]uint8
+	Ispeed uint32
+	Ospeed uint32
+}
+
+// Architecture dependent tty ioctls: TCSBRK, TCFLSH, TCGETS2,
+// TCSETSW2, TIOCGRS485 and TIOCSRS485.
+const (
+	ioctlBreak            = 0x5409
+	ioctlFlush            = 0x540b
+	ioctlGetTermios2      = 0x802c542a
+	ioctlSetTermios2Drain = 0x402c542c
+	ioctlGetRS485         = 0x542e
+	ioctlSetRS485         = 0x542f
+)
+
+// _IOC direction bits, see ioc.
+const (
+	iocWrite    = 1
+	iocRead     = 2
+	iocDirShift = 30
+)
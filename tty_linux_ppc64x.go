@@ -0,0 +1,39 @@
+//go:build linux && (ppc64 || ppc64le)
+// +build linux
+// +build ppc64 ppc64le
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "syscall"
+
+// Architecture dependent tty ioctls: TCSBRK, TCFLSH, TIOCGRS485 and
+// TIOCSRS485.
+const (
+	ioctlBreak    = 0x2000741d
+	ioctlFlush    = 0x2000741f
+	ioctlGetRS485 = 0x542e
+	ioctlSetRS485 = 0x542f
+)
+
+// There's no termios2 on powerpc, so only the standard baudrates are
+// supported.
+
+func setCustomSpeed(fd, baud int) error {
+	return syscall.EINVAL
+}
+
+func customSpeed(fd int) int {
+	return 0
+}
+
+// _IOC direction bits, see ioc.
+const (
+	iocWrite    = 4
+	iocRead     = 2
+	iocDirShift = 29
+)
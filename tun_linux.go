@@ -0,0 +1,67 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"bytes"
+	"syscall"
+	"unsafe"
+)
+
+// TUN/TAP interface flags.
+const (
+	iffTun  = 0x0001
+	iffTap  = 0x0002
+	iffNoPI = 0x1000
+)
+
+// tunIfreq is the part of struct ifreq used by TUNSETIFF, padded to
+// its size.
+type tunIfreq struct {
+	name  [syscall.IFNAMSIZ]byte
+	flags uint16
+	_     [22]byte
+}
+
+// OpenTun creates, or attaches to, the TUN (layer 3) interface name and
+// returns it as a File served by the default Poller, along with the
+// interface name. If name is empty, or a pattern like "tun%d", the
+// kernel picks a free name. Every Read returns one IP packet and every
+// Write sends one, without packet information header (IFF_NO_PI).
+func OpenTun(name string) (*File, string, error) {
+	return openTunTap(name, iffTun)
+}
+
+// OpenTap creates, or attaches to, the TAP (layer 2) interface name.
+// Every Read returns one Ethernet frame and every Write sends one. See
+// OpenTun.
+func OpenTap(name string) (*File, string, error) {
+	return openTunTap(name, iffTap)
+}
+
+func openTunTap(name string, flags uint16) (*File, string, error) {
+	fd, err := syscall.Open("/dev/net/tun", syscall.O_RDWR|syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, "", &OpError{Op: "open", Name: "/dev/net/tun", Err: err}
+	}
+	var ifr tunIfreq
+	copy(ifr.name[:len(ifr.name)-1], name)
+	ifr.flags = flags | iffNoPI
+	err = ioctl(fd, ioc(iocWrite, 'T', 202, unsafe.Sizeof(int32(0))), uintptr(unsafe.Pointer(&ifr)))
+	if err != nil {
+		syscall.Close(fd)
+		return nil, "", &OpError{Op: "open", Name: name, Err: err}
+	}
+	if i := bytes.IndexByte(ifr.name[:], 0); i >= 0 {
+		name = string(ifr.name[:i])
+	}
+	f, err := NewFile(uintptr(fd), name)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, "", err
+	}
+	return f, name, nil
+}
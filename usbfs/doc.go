@@ -0,0 +1,15 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+// Package usbfs performs bulk and interrupt transfers to USB devices
+// through the Linux usbfs interface (/dev/bus/usb/BBB/DDD), without cgo
+// or libusb. Transfers are submitted as URBs and reaped when the poll
+// event loop reports the device descriptor ready, so they can time out
+// without tying up a thread.
+//
+// The package is only implemented on Linux, Android included; there
+// apps get the descriptor of a device from the USB manager (see
+// NewDevice).
+package usbfs
@@ -0,0 +1,17 @@
+//go:build linux && (ppc64 || ppc64le)
+// +build linux
+// +build ppc64 ppc64le
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package usbfs
+
+// _IOC direction bits, see ioc.
+const (
+	iocWrite    = 4
+	iocRead     = 2
+	iocDirShift = 29
+)
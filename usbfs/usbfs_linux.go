@@ -0,0 +1,311 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package usbfs
+
+import (
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/jaracil/poll"
+)
+
+// URB types (USBDEVFS_URB_TYPE_*).
+const (
+	urbInterrupt = 1
+	urbBulk      = 3
+)
+
+// urb is struct usbdevfs_urb, without iso_frame_desc.
+type urb struct {
+	typ          uint8
+	endpoint     uint8
+	status       int32
+	flags        uint32
+	buffer       uintptr
+	bufferLength int32
+	actualLength int32
+	startFrame   int32
+	numPackets   int32
+	errorCount   int32
+	signr        uint32
+	userContext  uintptr
+}
+
+// usbfs ioctls (linux/usbdevice_fs.h).
+var (
+	ioctlSubmitURB        = ioc(iocRead, 'U', 10, unsafe.Sizeof(urb{}))
+	ioctlDiscardURB       = ioc(0, 'U', 11, 0)
+	ioctlReapURBNDelay    = ioc(iocWrite, 'U', 13, unsafe.Sizeof(uintptr(0)))
+	ioctlClaimInterface   = ioc(iocRead, 'U', 15, 4)
+	ioctlReleaseInterface = ioc(iocRead, 'U', 16, 4)
+	ioctlClearHalt        = ioc(iocRead, 'U', 21, 4)
+)
+
+// ioc encodes an ioctl request number like the Linux _IOC macro.
+func ioc(dir, typ, nr, size uintptr) uintptr {
+	return dir<<iocDirShift | size<<16 | typ<<8 | nr
+}
+
+func ioctl(fd, req, arg uintptr) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// transfer is a submitted URB.
+type transfer struct {
+	urb  urb
+	buf  []byte        // Data of the URB, owned by the kernel until reaped
+	done chan struct{} // Closed when reaped
+	err  error         // Set if the URB couldn't be reaped
+}
+
+// newTransfer returns a transfer of type typ on endpoint ep, for len(p)
+// bytes. The kernel keeps the address of the data until the URB is
+// reaped, maybe after the caller returns (e.g. if the Device is
+// closed), so it gets a heap buffer of its own, which the Go runtime
+// never moves, instead of p, which may live on a stack. Data written
+// to the device is copied to it.
+func newTransfer(typ uint8, ep uint8, p []byte) *transfer {
+	t := &transfer{buf: make([]byte, len(p)), done: make(chan struct{})}
+	t.urb.typ = typ
+	t.urb.endpoint = ep
+	t.urb.bufferLength = int32(len(p))
+	if len(p) > 0 {
+		if ep&0x80 == 0 {
+			copy(t.buf, p)
+		}
+		t.urb.buffer = uintptr(unsafe.Pointer(&t.buf[0]))
+	}
+	return t
+}
+
+// actual returns the number of bytes transferred by the reaped URB,
+// after copying those read from the device to p.
+func (t *transfer) actual(p []byte) int {
+	n := int(t.urb.actualLength)
+	if n < 0 || n > len(t.buf) {
+		n = 0
+	}
+	if t.urb.endpoint&0x80 != 0 {
+		copy(p, t.buf[:n])
+	}
+	return n
+}
+
+// Device is an open USB device. Its methods can be called
+// concurrently.
+type Device struct {
+	f       *poll.File
+	rc      syscall.RawConn
+	m       sync.Mutex
+	pending map[uintptr]*transfer // Submitted URBs, by address
+	orphans []*transfer           // Failed URBs the kernel may still own
+	err     error                 // Set when the reaper exits
+	reaped  chan struct{}         // Closed when the reaper exits
+}
+
+// Open opens the usbfs node of a device (e.g. "/dev/bus/usb/001/004").
+// The device is served by the default Poller.
+func Open(path string) (*Device, error) {
+	f, err := poll.Open(path, syscall.O_RDWR)
+	if err != nil {
+		return nil, err
+	}
+	return newDevice(f)
+}
+
+// NewDevice returns a Device for fd, an open usbfs node, e.g. the one
+// handed out by UsbDeviceConnection.getFileDescriptor on Android, where
+// apps can't open /dev/bus/usb themselves. Closing the Device closes
+// fd, so pass a duplicate if its owner keeps using it. The device is
+// served by the default Poller.
+func NewDevice(fd uintptr, name string) (*Device, error) {
+	f, err := poll.NewFile(fd, name)
+	if err != nil {
+		return nil, err
+	}
+	return newDevice(f)
+}
+
+func newDevice(f *poll.File) (*Device, error) {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d := &Device{
+		f:       f,
+		rc:      rc,
+		pending: map[uintptr]*transfer{},
+		reaped:  make(chan struct{}),
+	}
+	go d.reap()
+	return d, nil
+}
+
+// Close closes the device. Pending transfers are cancelled by the
+// kernel and fail with poll.ErrClosed.
+func (d *Device) Close() error {
+	err := d.f.Close()
+	<-d.reaped
+	// The kernel has cancelled pending URBs by now.
+	d.failPending(poll.ErrClosed)
+	d.m.Lock()
+	d.orphans = nil
+	d.m.Unlock()
+	return err
+}
+
+// reap reaps completed URBs, as the device descriptor becomes
+// writable, until the device is closed or fails.
+func (d *Device) reap() {
+	defer close(d.reaped)
+	var reapErr error
+	err := d.rc.Write(func(fd uintptr) bool {
+		for {
+			var addr uintptr
+			if err := ioctl(fd, ioctlReapURBNDelay, uintptr(unsafe.Pointer(&addr))); err != nil {
+				if err == syscall.EAGAIN {
+					return false
+				}
+				reapErr = err
+				return true
+			}
+			d.m.Lock()
+			t := d.pending[addr]
+			delete(d.pending, addr)
+			d.m.Unlock()
+			if t != nil {
+				close(t.done)
+			}
+		}
+	})
+	if err == nil {
+		err = reapErr
+	}
+	d.m.Lock()
+	d.err = err
+	d.m.Unlock()
+	if err != poll.ErrClosed {
+		d.failPending(err)
+	}
+}
+
+// failPending fails the pending transfers with err.
+func (d *Device) failPending(err error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	for addr, t := range d.pending {
+		if err != poll.ErrClosed {
+			// Not given back, keep its buffer until Close.
+			d.orphans = append(d.orphans, t)
+		}
+		t.err = err
+		close(t.done)
+		delete(d.pending, addr)
+	}
+}
+
+// control calls ioctl with the device descriptor.
+func (d *Device) control(req, arg uintptr) error {
+	var err error
+	if cerr := d.rc.Control(func(fd uintptr) {
+		err = ioctl(fd, req, arg)
+	}); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+// ClaimInterface claims interface n of the device, which must be done
+// before transferring data to its endpoints. The kernel driver bound to
+// the interface, if any, must be detached first.
+func (d *Device) ClaimInterface(n int) error {
+	iface := uint32(n)
+	return d.control(ioctlClaimInterface, uintptr(unsafe.Pointer(&iface)))
+}
+
+// ReleaseInterface releases interface n of the device.
+func (d *Device) ReleaseInterface(n int) error {
+	iface := uint32(n)
+	return d.control(ioctlReleaseInterface, uintptr(unsafe.Pointer(&iface)))
+}
+
+// ClearHalt clears the halt (stall) condition of endpoint ep.
+func (d *Device) ClearHalt(ep uint8) error {
+	e := uint32(ep)
+	return d.control(ioctlClearHalt, uintptr(unsafe.Pointer(&e)))
+}
+
+// Bulk performs a bulk transfer on endpoint ep: p is read from the
+// device if the direction bit (0x80) of ep is set, or written to it
+// otherwise. It returns the number of bytes transferred. If the
+// transfer doesn't complete within timeout (none if negative) it is
+// cancelled and poll.ErrTimeout is returned, along with the bytes
+// transferred so far.
+func (d *Device) Bulk(ep uint8, p []byte, timeout time.Duration) (int, error) {
+	return d.transfer(urbBulk, ep, p, timeout)
+}
+
+// Interrupt performs an interrupt transfer on endpoint ep. See Bulk.
+func (d *Device) Interrupt(ep uint8, p []byte, timeout time.Duration) (int, error) {
+	return d.transfer(urbInterrupt, ep, p, timeout)
+}
+
+func (d *Device) transfer(typ uint8, ep uint8, p []byte, timeout time.Duration) (int, error) {
+	t := newTransfer(typ, ep, p)
+	addr := uintptr(unsafe.Pointer(&t.urb))
+	d.m.Lock()
+	if d.err != nil {
+		d.m.Unlock()
+		return 0, d.opError(d.err)
+	}
+	d.pending[addr] = t
+	d.m.Unlock()
+	if err := d.control(ioctlSubmitURB, addr); err != nil {
+		d.m.Lock()
+		delete(d.pending, addr)
+		d.m.Unlock()
+		return 0, d.opError(err)
+	}
+	var expired <-chan time.Time
+	if timeout >= 0 {
+		tm := time.NewTimer(timeout)
+		defer tm.Stop()
+		expired = tm.C
+	}
+	timedOut := false
+	select {
+	case <-t.done:
+	case <-expired:
+		// The URB is still owned by the kernel, wait until it's
+		// given back.
+		timedOut = true
+		d.control(ioctlDiscardURB, addr)
+		<-t.done
+	}
+	if t.err != nil {
+		return 0, d.opError(t.err)
+	}
+	n := t.actual(p)
+	switch {
+	case t.urb.status == 0:
+		return n, nil
+	case timedOut && (syscall.Errno(-t.urb.status) == syscall.ENOENT ||
+		syscall.Errno(-t.urb.status) == syscall.ECONNRESET):
+		return n, d.opError(poll.ErrTimeout)
+	}
+	return n, d.opError(syscall.Errno(-t.urb.status))
+}
+
+func (d *Device) opError(err error) error {
+	return &poll.OpError{Op: "transfer", Name: d.f.Name(), Err: err}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package usbfs
+
+import (
+	"bytes"
+	"testing"
+	"unsafe"
+)
+
+func TestTransferOut(t *testing.T) {
+	p := []byte("hello")
+	tr := newTransfer(urbBulk, 0x01, p)
+	if tr.urb.buffer == uintptr(unsafe.Pointer(&p[0])) {
+		t.Fatal("URB uses the caller's buffer")
+	}
+	if tr.urb.buffer != uintptr(unsafe.Pointer(&tr.buf[0])) {
+		t.Fatal("URB doesn't use the transfer buffer")
+	}
+	if !bytes.Equal(tr.buf, p) {
+		t.Fatalf("buffer = %q, want %q", tr.buf, p)
+	}
+	if tr.urb.bufferLength != int32(len(p)) {
+		t.Fatalf("length = %d, want %d", tr.urb.bufferLength, len(p))
+	}
+	tr.urb.actualLength = 3
+	if n := tr.actual(p); n != 3 || string(p) != "hello" {
+		t.Fatalf("actual = %d, %q", n, p)
+	}
+}
+
+func TestTransferIn(t *testing.T) {
+	p := make([]byte, 8)
+	tr := newTransfer(urbInterrupt, 0x81, p)
+	// Data written by the kernel.
+	copy(tr.buf, "abcdefgh")
+	tr.urb.actualLength = 4
+	if n := tr.actual(p); n != 4 || string(p[:n]) != "abcd" || p[4] != 0 {
+		t.Fatalf("actual = %d, %q", n, p)
+	}
+	tr.urb.actualLength = 100
+	if n := tr.actual(p); n != 0 {
+		t.Fatalf("actual = %d with a bogus length", n)
+	}
+}
+
+func TestTransferEmpty(t *testing.T) {
+	tr := newTransfer(urbBulk, 0x81, nil)
+	if tr.urb.buffer != 0 || tr.urb.bufferLength != 0 {
+		t.Fatalf("URB buffer = %#x, %d", tr.urb.buffer, tr.urb.bufferLength)
+	}
+	if n := tr.actual(nil); n != 0 {
+		t.Fatalf("actual = %d", n)
+	}
+}
+
+func TestOpenMissing(t *testing.T) {
+	if _, err := Open("/dev/bus/usb/999/999"); err == nil {
+		t.Fatal("Open of a missing device succeeded")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// afVsock is AF_VSOCK, missing from package syscall.
+const afVsock = 40
+
+// Well known VSOCK context identifiers and ports.
+const (
+	VsockCIDAny        = 0xffffffff // Any address, for ListenVsock
+	VsockCIDHypervisor = 0          // The hypervisor
+	VsockCIDLocal      = 1          // Local communication (loopback)
+	VsockCIDHost       = 2          // The host, from a guest
+	VsockPortAny       = 0xffffffff // Any port, for ListenVsock
+)
+
+// sockaddrVM is struct sockaddr_vm.
+type sockaddrVM struct {
+	family uint16
+	_      uint16
+	port   uint32
+	cid    uint32
+	flags  uint8
+	_      [3]uint8
+}
+
+// VsockAddr is a VSOCK address, used for communication between
+// virtual machines and their host.
+type VsockAddr struct {
+	CID  uint32 // Context identifier
+	Port uint32
+}
+
+// Network returns "vsock".
+func (a VsockAddr) Network() string {
+	return "vsock"
+}
+
+// String returns the address as "cid:port".
+func (a VsockAddr) String() string {
+	return strconv.FormatUint(uint64(a.CID), 10) + ":" + strconv.FormatUint(uint64(a.Port), 10)
+}
+
+// DialVsock connects a VSOCK stream socket to port of the context cid
+// (e.g. VsockCIDHost from a guest), giving up at deadline (zero means
+// no deadline). The Conn is served by the default Poller.
+func DialVsock(cid, port uint32, deadline time.Time) (*Conn, error) {
+	sa := sockaddrVM{family: afVsock, cid: cid, port: port}
+	remote := VsockAddr{CID: cid, Port: port}
+	f, err := dialRaw(afVsock, syscall.SOCK_STREAM, 0, remote.String(),
+		unsafe.Pointer(&sa), unsafe.Sizeof(sa), deadline)
+	if err != nil {
+		return nil, err
+	}
+	var local net.Addr
+	if err := f.Lock(); err == nil {
+		if a, err := vsockName(f.fd); err == nil {
+			local = a
+		}
+		f.Unlock()
+	}
+	return NewConn(f, local, remote), nil
+}
+
+// vsockName returns the local address of the VSOCK socket fd.
+func vsockName(fd int) (VsockAddr, error) {
+	var sa sockaddrVM
+	l := uint32(unsafe.Sizeof(sa))
+	if err := rawGetsockname(fd, unsafe.Pointer(&sa), &l); err != nil {
+		return VsockAddr{}, err
+	}
+	return VsockAddr{CID: sa.cid, Port: sa.port}, nil
+}
+
+// VsockListener is a listening VSOCK stream socket, see ListenVsock.
+type VsockListener struct {
+	*File
+	addr VsockAddr
+}
+
+// ListenVsock listens for VSOCK connections on port (VsockPortAny picks
+// a free one) of any local context identifier. The listener is served
+// by the default Poller; the read deadline applies to Accept.
+func ListenVsock(port uint32) (*VsockListener, error) {
+	fd, err := sysSocket(afVsock, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	sa := sockaddrVM{family: afVsock, cid: VsockCIDAny, port: port}
+	err = rawBind(fd, unsafe.Pointer(&sa), unsafe.Sizeof(sa))
+	if err == nil {
+		err = syscall.Listen(fd, syscall.SOMAXCONN)
+	}
+	var addr VsockAddr
+	if err == nil {
+		addr, err = vsockName(fd)
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return nil, &OpError{Op: "listen", Name: "vsock", Err: err}
+	}
+	f, err := NewFile(uintptr(fd), addr.String())
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return &VsockListener{File: f, addr: addr}, nil
+}
+
+// Accept waits for and returns the next connection. It implements
+// net.Listener.
+func (l *VsockListener) Accept() (net.Conn, error) {
+	return l.AcceptConn()
+}
+
+// AcceptConn is like Accept, returning a *Conn, served by the same
+// Poller as the listener.
+func (l *VsockListener) AcceptConn() (*Conn, error) {
+	var sa sockaddrVM
+	nfd, err := l.acceptRaw(unsafe.Pointer(&sa), unsafe.Sizeof(sa))
+	if err != nil {
+		return nil, err
+	}
+	remote := VsockAddr{CID: sa.cid, Port: sa.port}
+	local := l.addr
+	if a, err := vsockName(nfd); err == nil {
+		local = a
+	}
+	f, err := l.p.NewFile(uintptr(nfd), remote.String())
+	if err != nil {
+		syscall.Close(nfd)
+		return nil, err
+	}
+	return NewConn(f, local, remote), nil
+}
+
+// Addr returns the address the listener is bound to.
+func (l *VsockListener) Addr() net.Addr {
+	return l.addr
+}
+
+var _ net.Listener = (*VsockListener)(nil)
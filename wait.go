@@ -0,0 +1,166 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"context"
+	"syscall"
+	"time"
+)
+
+// WaitRead blocks until File is readable, without consuming any data.
+// It returns ErrTimeout if the read deadline expires first, ErrClosed
+// if File is closed and ctx.Err() if ctx is done. Readiness includes
+// hang-up and error conditions, on which the following read reports
+// the end of file or the error.
+func (f *File) WaitRead(ctx context.Context) error {
+	return f.wait(ctx, false)
+}
+
+// WaitWrite blocks until File is writable. See WaitRead; the write
+// deadline applies.
+func (f *File) WaitWrite(ctx context.Context) error {
+	return f.wait(ctx, true)
+}
+
+// ready reports whether fd is readable (or writable, depending on
+// write) right now.
+func ready(fd int, write bool) (bool, error) {
+	pfd := []pollFd{{fd: int32(fd), events: pollIn}}
+	if write {
+		pfd[0].events = pollOut
+	}
+	for {
+		n, err := sysPoll(pfd, 0)
+		if err == syscall.EINTR {
+			continue
+		}
+		return n > 0, err
+	}
+}
+
+func (f *File) wait(ctx context.Context, write bool) error {
+	fdc := &f.r
+	if write {
+		fdc = &f.w
+	}
+	defer fdc.wakeOnDone(ctx)()
+	_, err := f.sysio(write, func(fd int) (int, error) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		ok, err := ready(fd, write)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, syscall.EAGAIN
+		}
+		return 0, nil
+	})
+	return err
+}
+
+// Pollable pairs a File with the readiness conditions a Wait caller is
+// interested in (Readable and/or Writable). Wait reports the observed
+// conditions in Revents; Hangup and ErrorEvent are always reported.
+type Pollable struct {
+	File    *File
+	Events  Events
+	Revents Events
+}
+
+// Wait blocks until at least one of the Files in ps is ready for the
+// requested Events and returns the number of ready entries, like
+// poll(2). A negative timeout means no timeout; on expiration Wait
+// returns ErrTimeout. If one of the Files is (or gets) closed Wait
+// returns ErrClosed. No data is consumed.
+func Wait(ps []Pollable, timeout time.Duration) (int, error) {
+	ch := make(chan struct{}, 1)
+	w := &watcher{fn: func(*File, Events) {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}}
+	for i := range ps {
+		ps[i].File.addWatcher(w)
+	}
+	defer func() {
+		for i := range ps {
+			ps[i].File.removeWatcher(w)
+		}
+	}()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	pfds := make([]pollFd, len(ps))
+	for {
+		for i := range ps {
+			f := ps[i].File
+			if f.isClosed() {
+				return 0, ErrClosed
+			}
+			pfds[i] = pollFd{fd: int32(f.fd)}
+			if ps[i].Events&Readable != 0 {
+				pfds[i].events |= pollIn
+			}
+			if ps[i].Events&Writable != 0 {
+				pfds[i].events |= pollOut
+			}
+		}
+		n, err := sysPoll(pfds, 0)
+		if err != nil && err != syscall.EINTR {
+			return 0, err
+		}
+		if n > 0 {
+			for i := range ps {
+				ps[i].Revents = pollEvents(pfds[i].revents)
+			}
+			return n, nil
+		}
+		if timeout == 0 {
+			return 0, ErrTimeout
+		}
+		for i := range ps {
+			if ps[i].Events&Readable != 0 {
+				ps[i].File.p.startTrack(ps[i].File.fd, false)
+			}
+			if ps[i].Events&Writable != 0 {
+				ps[i].File.p.startTrack(ps[i].File.fd, true)
+			}
+		}
+		select {
+		case <-ch:
+		case <-expired:
+			return 0, ErrTimeout
+		}
+	}
+}
+
+// pollEvents translates poll(2) revents to Events.
+func pollEvents(revents int16) Events {
+	var events Events
+	if revents&pollIn != 0 {
+		events |= Readable
+	}
+	if revents&pollOut != 0 {
+		events |= Writable
+	}
+	if revents&pollHup != 0 {
+		events |= Hangup
+	}
+	if revents&(pollErr|pollNval) != 0 {
+		events |= ErrorEvent
+	}
+	return events
+}
@@ -0,0 +1,23 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitReadCancel(t *testing.T) {
+	r, _ := newPipe(t)
+	cancelLoop(t, func(ctx context.Context) error {
+		r.SetReadDeadline(time.Now().Add(2 * time.Second))
+		return r.WaitRead(ctx)
+	})
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2015, Jose Luis Aracil Gomez (pepe@diselpro.com)
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package poll
+
+import "time"
+
+// WriteAtTime waits until instant t and then writes p, like Write, so
+// time-slotted (TDMA) protocols over serial or radio links can transmit
+// at given instants. If t has passed p is written right away. The
+// wait is timed by the event loop when TimerFD is set and, like the
+// write, ends with ErrTimeout when the write deadline expires or with
+// ErrClosed if File is closed. Writes not scheduled may go out while
+// WriteAtTime is waiting.
+func (f *File) WriteAtTime(t time.Time, p []byte) (n int, err error) {
+	if err := f.waitUntil(t); err != nil {
+		return 0, f.opError("write", err)
+	}
+	return f.Write(p)
+}
+
+// waitUntil waits on the write side of File until t.
+func (f *File) waitUntil(t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return nil
+	}
+	fdc := &f.w
+	fired := false
+	tm := f.p.newTimer(d, func() {
+		fdc.cond.L.Lock()
+		fired = true
+		fdc.cond.Broadcast()
+		fdc.cond.L.Unlock()
+	})
+	defer tm.Stop()
+	fdc.cond.L.Lock()
+	defer fdc.cond.L.Unlock()
+	for !fired {
+		if f.closed {
+			return ErrClosed
+		}
+		if fdc.timeout {
+			return ErrTimeout
+		}
+		fdc.waiters++
+		fdc.cond.Wait()
+		fdc.waiters--
+	}
+	return nil
+}